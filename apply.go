@@ -0,0 +1,61 @@
+package eventsource
+
+import (
+	"context"
+	"fmt"
+)
+
+// FallibleEvent is implemented by events whose application to an aggregate
+// can fail. ApplyToE is used instead of ApplyTo when an event implements it.
+type FallibleEvent interface {
+	ApplyToE(ctx context.Context, aggregate Aggregate) error
+}
+
+// ApplyEvent applies the event to the aggregate, through ApplyToE when the
+// event implements FallibleEvent.
+func ApplyEvent(ctx context.Context, a Aggregate, e Event) error {
+	if fe, ok := e.(FallibleEvent); ok {
+		return fe.ApplyToE(ctx, a)
+	}
+
+	e.ApplyTo(ctx, a)
+
+	return nil
+}
+
+// ApplyError is returned when an event cannot be applied to its aggregate.
+type ApplyError struct {
+	EventID          EventID
+	EventType        EventType
+	AggregateID      AggregateID
+	AggregateType    AggregateType
+	AggregateVersion AggregateVersion
+	Err              error
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("could not apply event '%s' of type '%s' at version %d of aggregate '%s': %v",
+		e.EventID, e.EventType, e.AggregateVersion, e.AggregateID, e.Err)
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// SnapshotError is returned when a snapshot cannot be restored into its
+// aggregate.
+type SnapshotError struct {
+	AggregateID      AggregateID
+	AggregateType    AggregateType
+	AggregateVersion AggregateVersion
+	Err              error
+}
+
+func (e *SnapshotError) Error() string {
+	return fmt.Sprintf("could not restore snapshot at version %d of aggregate '%s': %v",
+		e.AggregateVersion, e.AggregateID, e.Err)
+}
+
+func (e *SnapshotError) Unwrap() error {
+	return e.Err
+}
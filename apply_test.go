@@ -0,0 +1,99 @@
+package eventsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errOverdrawn = errors.New("overdrawn")
+
+type withdrawn struct {
+	*BaseEvent
+	Amount int `es:"amount"`
+}
+
+func (e *withdrawn) Type() EventType {
+	return "withdrawn"
+}
+
+func (e *withdrawn) ApplyTo(context.Context, Aggregate) {}
+
+func (e *withdrawn) ApplyToE(context.Context, Aggregate) error {
+	if e.Amount > 10 {
+		return errOverdrawn
+	}
+
+	return nil
+}
+
+func TestRaise_ApplyError(t *testing.T) {
+	ctx := context.Background()
+	a := InitAggregate("agg_1", "account")
+
+	if err := Raise(ctx, a, &withdrawn{BaseEvent: NewBaseEvent(a, nil), Amount: 5}); err != nil {
+		t.Fatalf("Raise() error = %v", err)
+	}
+
+	failing := &withdrawn{BaseEvent: NewBaseEvent(a, nil), Amount: 50}
+
+	err := Raise(ctx, a, failing)
+
+	var applyErr *ApplyError
+	if !errors.As(err, &applyErr) || !errors.Is(err, errOverdrawn) {
+		t.Fatalf("Raise() error = %v, want an *ApplyError wrapping %v", err, errOverdrawn)
+	}
+
+	if applyErr.EventID != failing.ID() || applyErr.AggregateVersion != 2 {
+		t.Errorf("Raise() error = %+v, want event %s at version 2", applyErr, failing.ID())
+	}
+
+	if a.Version() != 1 || len(a.Changes()) != 1 {
+		t.Errorf("Raise() left the aggregate at version %d with %d changes, want 1 and 1", a.Version(), len(a.Changes()))
+	}
+}
+
+func TestReplay_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	e := &withdrawn{BaseEvent: initBaseEvent("evt_1", time.Now(), "agg_1", "account", 1, nil), Amount: 50}
+
+	if _, err := Replay(ctx, InitAggregate("agg_1", "account"), nil, e); !errors.Is(err, errOverdrawn) {
+		t.Errorf("Replay() error = %v, want %v", err, errOverdrawn)
+	}
+
+	corrupted := &Snapshot{AggregateID: "agg_1", AggregateType: "account", AggregateVersion: 3, SchemaVersion: 1, Data: []byte("{")}
+
+	var snapshotErr *SnapshotError
+	if _, err := Replay(ctx, InitAggregate("agg_1", "account"), corrupted); !errors.As(err, &snapshotErr) || snapshotErr.AggregateVersion != 3 {
+		t.Errorf("Replay() error = %v, want a *SnapshotError at version 3", err)
+	}
+}
+
+func TestFromSnapshot_SchemaVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		schemaVersion int
+		wantErr       error
+	}{
+		{name: "default version", schemaVersion: 1},
+		{name: "unversioned", schemaVersion: 0},
+		{name: "other version", schemaVersion: 2, wantErr: ErrIncompatibleSnapshot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := &Snapshot{AggregateID: "agg_1", AggregateType: "account", AggregateVersion: 3, SchemaVersion: tt.schemaVersion, Data: []byte("{}")}
+
+			a := InitAggregate("agg_1", "account")
+			if err := FromSnapshot(snapshot, a); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FromSnapshot() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && a.Version() != 3 {
+				t.Errorf("FromSnapshot() version = %d, want 3", a.Version())
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package eventsource
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// BusHandler reacts in-process to the events published on an EventBus. It is
+// called after the transaction of the events is committed: its errors and
+// panics are logged and cannot undo the save.
+type BusHandler func(ctx context.Context, e EventReadModel) error
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// EventBus hands the events saved through a store returned by
+// NewPublishingEventStore to the handlers subscribed to them, once the
+// transaction given by Transaction is committed. Events are handled
+// synchronously, in the goroutine committing the transaction, in the order
+// they were saved.
+type EventBus struct {
+	mu            sync.RWMutex
+	subscriptions []busSubscription
+	next          int
+}
+
+type busSubscription struct {
+	id      int
+	options *ReadOptions
+	handler BusHandler
+}
+
+// handle hands the event to the handler, reporting its panic as an error.
+func (s busSubscription) handle(ctx context.Context, e EventReadModel) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("event handler panicked: %v", r)
+		}
+	}()
+
+	return s.handler(ctx, e)
+}
+
+// Subscribe hands the published events to the handler, restricted to the
+// aggregate and event types given with WithAggregateTypes and WithEventTypes.
+// The returned function cancels the subscription.
+func (b *EventBus) Subscribe(handler BusHandler, opts ...ReadOption) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+
+	b.subscriptions = append(b.subscriptions, busSubscription{
+		id:      id,
+		options: NewReadOptions(opts...),
+		handler: handler,
+	})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for i, s := range b.subscriptions {
+			if s.id == id {
+				b.subscriptions = append(b.subscriptions[:i:i], b.subscriptions[i+1:]...)
+
+				break
+			}
+		}
+	}
+}
+
+// Publish hands the events to the handlers subscribed to them. Handler errors
+// and panics are logged, so that the other handlers still get the events.
+func (b *EventBus) Publish(ctx context.Context, ee ...EventReadModel) {
+	b.mu.RLock()
+	subscriptions := b.subscriptions
+	b.mu.RUnlock()
+
+	for _, e := range ee {
+		for _, s := range subscriptions {
+			if !s.options.Matches(e) {
+				continue
+			}
+
+			if err := s.handle(ctx, e); err != nil {
+				LoggerFromContext(ctx).Log(ctx, LevelError, "event handler failed",
+					append(EventReadModelFields(e), Field{Key: "error", Value: err.Error()})...)
+			}
+		}
+	}
+}
+
+// Transaction wraps tx so that the events saved with it through a publishing
+// event store are published on the bus when it is committed, with ctx, and
+// dropped when it is rolled back.
+func (b *EventBus) Transaction(ctx context.Context, tx Transaction) Transaction {
+	return &busTransaction{
+		Transaction: tx,
+		ctx:         ctx,
+		bus:         b,
+	}
+}
+
+// BeginFunc returns a BeginFunc wrapping the transactions of begin with
+// Transaction, e.g. for a Dispatcher or a Subscriber.
+func (b *EventBus) BeginFunc(begin BeginFunc) BeginFunc {
+	return func(ctx context.Context) (Transaction, error) {
+		tx, err := begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return b.Transaction(ctx, tx), nil
+	}
+}
+
+type busTransaction struct {
+	Transaction
+
+	ctx context.Context
+	bus *EventBus
+
+	mu     sync.Mutex
+	events []EventReadModel
+}
+
+func (t *busTransaction) Unwrap() Transaction {
+	return t.Transaction
+}
+
+func (t *busTransaction) Commit() error {
+	if err := t.Transaction.Commit(); err != nil {
+		t.drain()
+
+		return err
+	}
+
+	t.bus.Publish(t.ctx, t.drain()...)
+
+	return nil
+}
+
+func (t *busTransaction) Rollback() error {
+	t.drain()
+
+	return t.Transaction.Rollback()
+}
+
+func (t *busTransaction) append(ee ...EventReadModel) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, ee...)
+}
+
+func (t *busTransaction) drain() []EventReadModel {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ee := t.events
+	t.events = nil
+
+	return ee
+}
+
+// NewPublishingEventStore returns an EventStore decorating store so that the
+// events saved with a transaction returned by EventBus.Transaction are
+// published on its bus once it is committed. Saves with other transactions are
+// not published. The position of the published events is not known.
+func NewPublishingEventStore(store EventStore) EventStore {
+	return &publishingEventStore{EventStore: store}
+}
+
+type publishingEventStore struct {
+	EventStore
+}
+
+func (s *publishingEventStore) Save(ctx context.Context, tx Transaction, a Aggregate, opts ...SaveOption) error {
+	changes := a.Changes()
+
+	if err := s.EventStore.Save(ctx, tx, a, opts...); err != nil {
+		return err
+	}
+
+	t, ok := TransactionAs[*busTransaction](tx)
+	if !ok {
+		return nil
+	}
+
+	ee := make([]EventReadModel, 0, len(changes))
+	for _, change := range changes {
+		e, err := NewEventReadModel(change)
+		if err != nil {
+			return err
+		}
+
+		ee = append(ee, e)
+	}
+
+	t.append(ee...)
+
+	return nil
+}
+
+// PurgeSnapshots deletes the outdated snapshots through the decorated event
+// store when it is a SnapshotPurger.
+func (s *publishingEventStore) PurgeSnapshots(ctx context.Context, tx Transaction, aggregateType AggregateType, schemaVersion int) (int64, error) {
+	purger, ok := s.EventStore.(SnapshotPurger)
+	if !ok {
+		return 0, fmt.Errorf("%w: %T", ErrSnapshotPurgeNotSupported, s.EventStore)
+	}
+
+	return purger.PurgeSnapshots(ctx, tx, aggregateType, schemaVersion)
+}
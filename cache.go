@@ -0,0 +1,264 @@
+package eventsource
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type CacheOption func(*CacheOptions)
+
+// WithCacheSize sets the maximum number of aggregates kept by the cache.
+func WithCacheSize(size int) CacheOption {
+	return func(opt *CacheOptions) {
+		if size > 0 {
+			opt.Size = size
+		}
+	}
+}
+
+// WithCacheRegistry makes the cache decode the events it reads with the
+// registry instead of the aggregate's ParseEvents. It defaults to the registry
+// of the decorated event store when it implements RegistryProvider.
+func WithCacheRegistry(r *Registry) CacheOption {
+	return func(opt *CacheOptions) {
+		opt.Registry = r
+	}
+}
+
+func NewCacheOptions(opts ...CacheOption) *CacheOptions {
+	const (
+		defaultSize = 1000
+	)
+
+	result := &CacheOptions{
+		Size: defaultSize,
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+type CacheOptions struct {
+	Size     int
+	Registry *Registry
+}
+
+// NewCachingEventStore returns an EventStore decorating store with a least
+// recently used cache of the aggregates it loads. The cache keeps the
+// serialized state of the aggregates, like a snapshot, and rehydrates the
+// aggregate given to each Load from it, so that callers never share an
+// instance. A cached aggregate is brought up to date with the events stored
+// after its version and is evicted whenever Save is called for it.
+//
+// The cache checks that its last event is still stored before using it, so
+// that aggregates loaded from transactions that were rolled back are reloaded
+// from the store.
+func NewCachingEventStore(store EventStore, opts ...CacheOption) *CachingEventStore {
+	options := NewCacheOptions(opts...)
+	if p, implements := store.(RegistryProvider); implements && options.Registry == nil {
+		options.Registry = p.Registry()
+	}
+
+	return &CachingEventStore{
+		EventStore: store,
+		options:    options,
+		entries:    make(map[cacheKey]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// CachingEventStore is the EventStore returned by NewCachingEventStore.
+type CachingEventStore struct {
+	EventStore
+
+	options *CacheOptions
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+}
+
+type cacheKey struct {
+	id AggregateID
+	t  AggregateType
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	snapshot Snapshot
+	// lastEventID is the id of the event of the version of the snapshot.
+	lastEventID EventID
+}
+
+// Save saves the aggregate through the decorated event store and evicts it
+// from the cache, whether it succeeds or fails with a concurrency conflict.
+func (s *CachingEventStore) Save(ctx context.Context, tx Transaction, a Aggregate, opts ...SaveOption) error {
+	err := s.EventStore.Save(ctx, tx, a, opts...)
+
+	s.Invalidate(a.ID(), a.Type())
+
+	return err
+}
+
+func (s *CachingEventStore) Load(ctx context.Context, tx Transaction, a Aggregate) (Aggregate, error) {
+	if a.ID().IsZero() || a.Type().IsZero() {
+		return s.EventStore.Load(ctx, tx, a)
+	}
+
+	if entry, ok := s.get(a.ID(), a.Type()); ok {
+		loaded, lastEventID, err := s.refresh(ctx, tx, a, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if loaded != nil {
+			if lastEventID != entry.lastEventID {
+				s.put(ctx, loaded, lastEventID)
+			}
+
+			return loaded, nil
+		}
+
+		s.Invalidate(a.ID(), a.Type())
+	}
+
+	loaded, err := s.EventStore.Load(ctx, tx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	ee, err := s.EventStore.EventsHistory(ctx, tx, loaded.ID().String(), loaded.Type().String(), loaded.Version().Int(), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ee) == 1 && ee[0].AggregateVersion == loaded.Version() {
+		s.put(ctx, loaded, ee[0].ID)
+	}
+
+	return loaded, nil
+}
+
+// PurgeSnapshots deletes the outdated snapshots through the decorated event
+// store when it is a SnapshotPurger.
+func (s *CachingEventStore) PurgeSnapshots(ctx context.Context, tx Transaction, aggregateType AggregateType, schemaVersion int) (int64, error) {
+	purger, ok := s.EventStore.(SnapshotPurger)
+	if !ok {
+		return 0, fmt.Errorf("%w: %T", ErrSnapshotPurgeNotSupported, s.EventStore)
+	}
+
+	return purger.PurgeSnapshots(ctx, tx, aggregateType, schemaVersion)
+}
+
+// Invalidate evicts the aggregate from the cache.
+func (s *CachingEventStore) Invalidate(id AggregateID, t AggregateType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[cacheKey{id: id, t: t}]; ok {
+		s.lru.Remove(e)
+		delete(s.entries, cacheKey{id: id, t: t})
+	}
+}
+
+// Registry returns the registry the cache decodes events with.
+func (s *CachingEventStore) Registry() *Registry {
+	return s.options.Registry
+}
+
+// Len returns the number of aggregates in the cache.
+func (s *CachingEventStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lru.Len()
+}
+
+// refresh rehydrates the aggregate from the entry and replays the events
+// stored after it. It returns a nil aggregate when the last event of the entry
+// is no longer stored.
+func (s *CachingEventStore) refresh(ctx context.Context, tx Transaction, a Aggregate, entry cacheEntry) (Aggregate, EventID, error) {
+	ee, err := s.EventStore.EventsHistory(ctx, tx, a.ID().String(), a.Type().String(), entry.snapshot.AggregateVersion.Int(), 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(ee) == 0 || ee[0].AggregateVersion != entry.snapshot.AggregateVersion || ee[0].ID != entry.lastEventID {
+		return nil, "", nil
+	}
+
+	a.PrepareForLoading()
+
+	var events []Event
+	if len(ee) > 1 {
+		events, err = ParseEvents(ctx, s.options.Registry, a, ee[1:]...)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	loaded, err := Replay(ctx, a, &entry.snapshot, events...)
+	if err != nil {
+		var snapshotErr *SnapshotError
+		if errors.As(err, &snapshotErr) {
+			return nil, "", nil
+		}
+
+		return nil, "", err
+	}
+
+	return loaded, ee[len(ee)-1].ID, nil
+}
+
+func (s *CachingEventStore) get(id AggregateID, t AggregateType) (cacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[cacheKey{id: id, t: t}]
+	if !ok {
+		return cacheEntry{}, false
+	}
+
+	s.lru.MoveToFront(e)
+
+	return e.Value.(cacheEntry), true
+}
+
+// put caches the aggregate. Aggregates that cannot be serialized are not
+// cached, which only costs the next Load a round trip.
+func (s *CachingEventStore) put(ctx context.Context, a Aggregate, lastEventID EventID) {
+	snapshot, err := NewSnapshot(a)
+	if err != nil {
+		LoggerFromContext(ctx).Log(ctx, LevelDebug, "aggregate not cached",
+			append(AggregateFields(a), Field{Key: "error", Value: err.Error()})...)
+
+		return
+	}
+
+	key := cacheKey{id: a.ID(), t: a.Type()}
+	entry := cacheEntry{key: key, snapshot: *snapshot, lastEventID: lastEventID}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.Value = entry
+		s.lru.MoveToFront(e)
+
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(entry)
+
+	for s.lru.Len() > s.options.Size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(cacheEntry).key)
+	}
+}
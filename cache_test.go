@@ -0,0 +1,50 @@
+package eventsource
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// unserializable cannot be marshaled into a snapshot.
+type unserializable struct {
+	*BaseAggregate
+	Updates chan int `es:"updates"`
+}
+
+// loadingStore loads aggregates at version 1 without a database.
+type loadingStore struct {
+	EventStore
+}
+
+func (s loadingStore) Load(_ context.Context, _ Transaction, a Aggregate) (Aggregate, error) {
+	a.SetVersion(1)
+
+	return a, nil
+}
+
+func (s loadingStore) EventsHistory(_ context.Context, _ Transaction, aggregateID, aggregateType string, _ int, _ int) ([]EventReadModel, error) {
+	return []EventReadModel{{ID: "evt_1", AggregateID: AggregateID(aggregateID), AggregateType: AggregateType(aggregateType), AggregateVersion: 1}}, nil
+}
+
+func TestCachingEventStore_Unserializable(t *testing.T) {
+	b := bytes.Buffer{}
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	ctx := ContextWithLogger(context.Background(), logger)
+	cache := NewCachingEventStore(loadingStore{})
+
+	if _, err := cache.Load(ctx, nil, &unserializable{BaseAggregate: InitAggregate("agg_1", "account")}); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", cache.Len())
+	}
+
+	if !strings.Contains(b.String(), `"msg":"aggregate not cached"`) {
+		t.Errorf("Load() logged %q, want the aggregate not to be cached", b.String())
+	}
+}
@@ -0,0 +1,246 @@
+package eventsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownCommandType = errors.New("unknown command type")
+)
+
+type CommandType string
+
+func (t CommandType) String() string {
+	return string(t)
+}
+
+// Command is a request to change the aggregate it targets.
+type Command interface {
+	Type() CommandType
+	AggregateID() AggregateID
+}
+
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		handlers: make(map[CommandType]commandHandler),
+	}
+}
+
+// CommandRegistry associates the command types with their handlers.
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	handlers map[CommandType]commandHandler
+}
+
+type commandHandler struct {
+	// aggregate returns an empty aggregate with the id, to be loaded.
+	aggregate func(id AggregateID) Aggregate
+	handle    func(ctx context.Context, a Aggregate, c Command) error
+}
+
+// RegisterCommand associates the command type with the handler changing the
+// aggregate built by factory, typically by raising events. The aggregate is
+// loaded before the handler is called, or given at version 0 when it does not
+// exist yet. Registering the same type twice replaces the previous handler.
+//
+//	eventsource.RegisterCommand(r, "close_order", NewOrder, func(ctx context.Context, o *Order, c *CloseOrder) error {
+//		return o.Close(ctx, c.Reason)
+//	})
+func RegisterCommand[C Command, A Aggregate](r *CommandRegistry, t CommandType, factory func(id AggregateID) A, handler func(ctx context.Context, a A, c C) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[t] = commandHandler{
+		aggregate: func(id AggregateID) Aggregate {
+			return factory(id)
+		},
+		handle: func(ctx context.Context, a Aggregate, c Command) error {
+			command, ok := c.(C)
+			if !ok {
+				return fmt.Errorf("command of type '%s' is a %T", t, c)
+			}
+
+			return handler(ctx, a.(A), command)
+		},
+	}
+}
+
+// IsRegistered reports whether a handler is registered for the command type.
+func (r *CommandRegistry) IsRegistered(t CommandType) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.handlers[t]
+
+	return exists
+}
+
+func (r *CommandRegistry) handler(t CommandType) (commandHandler, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	h, exists := r.handlers[t]
+	if !exists {
+		return commandHandler{}, fmt.Errorf("%w: '%s'", ErrUnknownCommandType, t)
+	}
+
+	return h, nil
+}
+
+type DispatchOption func(*DispatchOptions)
+
+// WithMaxRetries sets how many times a command is dispatched again after a
+// concurrency conflict before the conflict is returned.
+func WithMaxRetries(retries int) DispatchOption {
+	return func(opt *DispatchOptions) {
+		if retries >= 0 {
+			opt.MaxRetries = retries
+		}
+	}
+}
+
+// WithRetryBackoff sets the delay before dispatching a command again after a
+// concurrency conflict.
+func WithRetryBackoff(backoff Backoff) DispatchOption {
+	return func(opt *DispatchOptions) {
+		opt.Backoff = backoff
+	}
+}
+
+// WithDispatchSaveOptions sets the options the aggregates are saved with.
+func WithDispatchSaveOptions(opts ...SaveOption) DispatchOption {
+	return func(opt *DispatchOptions) {
+		opt.SaveOptions = append(opt.SaveOptions, opts...)
+	}
+}
+
+func NewDispatchOptions(opts ...DispatchOption) *DispatchOptions {
+	const (
+		defaultMaxRetries = 3
+	)
+
+	result := &DispatchOptions{
+		MaxRetries: defaultMaxRetries,
+		Backoff:    ExponentialBackoff(10*time.Millisecond, time.Second),
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+type DispatchOptions struct {
+	MaxRetries  int
+	Backoff     Backoff
+	SaveOptions []SaveOption
+}
+
+func NewDispatcher(store EventStore, begin BeginFunc, registry *CommandRegistry, opts ...DispatchOption) *Dispatcher {
+	return &Dispatcher{
+		store:    store,
+		begin:    begin,
+		registry: registry,
+		options:  NewDispatchOptions(opts...),
+	}
+}
+
+// Dispatcher handles the commands with the handlers of a registry.
+type Dispatcher struct {
+	store    EventStore
+	begin    BeginFunc
+	registry *CommandRegistry
+	options  *DispatchOptions
+}
+
+// Dispatch loads the aggregate targeted by the command, hands it to the
+// handler of the command and saves its changes, in a transaction of its own.
+// When the save reports a concurrency conflict the whole sequence is run
+// again, from a fresh load, up to MaxRetries times.
+func (d *Dispatcher) Dispatch(ctx context.Context, c Command) error {
+	h, err := d.registry.handler(c.Type())
+	if err != nil {
+		return err
+	}
+
+	return d.retry(ctx, c, func() error {
+		return d.dispatch(ctx, h, c)
+	})
+}
+
+// DispatchTx dispatches the command like Dispatch but within tx, so that its
+// changes are committed with the other writes of the transaction. Conflicting
+// saves are not retried: the error wrapping ErrConcurrencyConflict is returned
+// for the caller to roll back tx and run the transaction again.
+func (d *Dispatcher) DispatchTx(ctx context.Context, tx Transaction, c Command) error {
+	h, err := d.registry.handler(c.Type())
+	if err != nil {
+		return err
+	}
+
+	return d.dispatchTx(ctx, tx, h, c)
+}
+
+// retry runs dispatch again after each concurrency conflict it reports, up to
+// MaxRetries times.
+func (d *Dispatcher) retry(ctx context.Context, c Command, dispatch func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := dispatch()
+		if err == nil || !errors.Is(err, ErrConcurrencyConflict) || attempt > d.options.MaxRetries {
+			return err
+		}
+
+		LoggerFromContext(ctx).Log(ctx, LevelDebug, "command retried",
+			Field{Key: "command_type", Value: c.Type().String()},
+			Field{Key: "aggregate_id", Value: c.AggregateID().String()},
+			Field{Key: "attempt", Value: attempt},
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.options.Backoff(attempt)):
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, h commandHandler, c Command) error {
+	tx, err := d.begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := d.dispatchTx(ctx, tx, h, c); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d *Dispatcher) dispatchTx(ctx context.Context, tx Transaction, h commandHandler, c Command) error {
+	a := h.aggregate(c.AggregateID())
+
+	loaded, err := d.store.Load(ctx, tx, a)
+	if errors.Is(err, ErrAggregateDoNotExist) {
+		loaded = a
+	} else if err != nil {
+		return err
+	}
+
+	if err := h.handle(ctx, loaded, c); err != nil {
+		return err
+	}
+
+	if len(loaded.Changes()) == 0 {
+		return nil
+	}
+
+	return d.store.Save(ctx, tx, loaded, d.options.SaveOptions...)
+}
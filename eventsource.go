@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 
 	jsoniter "github.com/json-iterator/go"
 )
@@ -22,9 +21,11 @@ func AssertAndGet[H Aggregate, W Aggregate](have H, want W) (W, error) {
 	return r, nil
 }
 
-func Raise(ctx context.Context, aggregate Aggregate, changes ...Event) {
+// Raise applies the changes to the aggregate and stacks them to be saved. It
+// stops at the first change that cannot be applied, which is not stacked.
+func Raise(ctx context.Context, aggregate Aggregate, changes ...Event) error {
 	if aggregate == nil || len(changes) == 0 {
-		return
+		return nil
 	}
 
 	for _, e := range changes {
@@ -32,47 +33,67 @@ func Raise(ctx context.Context, aggregate Aggregate, changes ...Event) {
 			continue
 		}
 
-		aggregate.StackChange(e)
-
 		e.SetVersion(aggregate.Version() + 1)
 
-		On(ctx, aggregate, e, true)
+		if err := On(ctx, aggregate, e, true); err != nil {
+			return err
+		}
+
+		aggregate.StackChange(e)
 	}
+
+	return nil
 }
 
-func On(ctx context.Context, a Aggregate, event Event, new bool) {
+// On applies the event to the aggregate when it is newer than the aggregate,
+// and returns an *ApplyError when it cannot be applied.
+func On(ctx context.Context, a Aggregate, event Event, new bool) error {
 	if a == nil || event == nil {
-		return
+		return nil
 	}
 
 	if a.Version() < event.AggregateVersion() {
-		event.ApplyTo(ctx, a)
-
-		action := "replayed"
-		if new {
-			action = "raised"
+		if err := ApplyEvent(ctx, a, event); err != nil {
+			return &ApplyError{
+				EventID:          event.ID(),
+				EventType:        event.Type(),
+				AggregateID:      event.AggregateID(),
+				AggregateType:    event.AggregateType(),
+				AggregateVersion: event.AggregateVersion(),
+				Err:              err,
+			}
 		}
 
-		log.Printf("%s event `%s` with id `%s` on aggregate with id `%s`", action, event.Type(), event.ID(), event.AggregateID())
+		logger := LoggerFromContext(ctx)
+		if logger.Enabled(ctx, LevelDebug) {
+			msg := "event replayed"
+			if new {
+				msg = "event raised"
+			}
 
-		a.IncrementVersion()
-
-		snap, err := NewSnapshot(a)
-		if err != nil {
-			log.Println(err)
+			logger.Log(ctx, LevelDebug, msg, EventFields(event)...)
 		}
 
-		a.StackSnapshot(snap)
+		a.IncrementVersion()
 	}
+
+	return nil
 }
 
+// Replay restores the aggregate from the snapshot, if any, and applies the
+// events in version order. It fails on the first snapshot or event that cannot
+// be applied.
 func Replay(ctx context.Context, a Aggregate, snapshot *Snapshot, ee ...Event) (Aggregate, error) {
-	FromSnapshot(snapshot, a)
+	if err := FromSnapshot(snapshot, a); err != nil {
+		return nil, err
+	}
 
 	Sort(ee)
 
 	for _, e := range ee {
-		On(ctx, a, e, false)
+		if err := On(ctx, a, e, false); err != nil {
+			return nil, err
+		}
 	}
 
 	return a, nil
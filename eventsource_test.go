@@ -0,0 +1,51 @@
+package eventsource
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type ledger struct {
+	*BaseAggregate
+	Entries []string `es:"entries"`
+}
+
+type entryAdded struct {
+	*BaseEvent
+	Entry string `es:"entry"`
+}
+
+func (e *entryAdded) Type() EventType {
+	return "entry_added"
+}
+
+func (e *entryAdded) ApplyTo(_ context.Context, a Aggregate) {
+	l := a.(*ledger)
+	l.Entries = append(l.Entries, e.Entry)
+}
+
+func BenchmarkReplay(b *testing.B) {
+	for _, n := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("%d events", n), func(b *testing.B) {
+			events := make([]Event, 0, n)
+			for i := 1; i <= n; i++ {
+				base := initBaseEvent(NewEventID(), time.Now(), "agg_1", "ledger", AggregateVersion(i), nil)
+				events = append(events, &entryAdded{BaseEvent: base, Entry: fmt.Sprintf("entry %d", i)})
+			}
+
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				l := &ledger{BaseAggregate: InitAggregate("agg_1", "ledger")}
+				if _, err := Replay(ctx, l, nil, events...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
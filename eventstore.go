@@ -3,19 +3,57 @@ package eventsource
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 )
 
 var (
-	ErrNoEventsToStore       = errors.New("no events to store")
-	ErrNoSnapshotFound       = errors.New("no snapshot found")
-	ErrTransactionIsRequired = errors.New("transaction is required")
-	ErrAggregateDoNotExist   = errors.New("aggregate do not exist")
+	ErrNoEventsToStore        = errors.New("no events to store")
+	ErrNoSnapshotFound        = errors.New("no snapshot found")
+	ErrTransactionIsRequired  = errors.New("transaction is required")
+	ErrAggregateDoNotExist    = errors.New("aggregate do not exist")
+	ErrConcurrencyConflict    = errors.New("concurrency conflict")
+	ErrUnsupportedTransaction = errors.New("unsupported transaction")
 )
 
 func ErrIsSnapshotNotFound(err error) bool {
 	return errors.Is(err, ErrNoSnapshotFound)
 }
 
+// ConcurrencyConflictError is returned by Save when the stream of the
+// aggregate is not at the expected version. It matches ErrConcurrencyConflict
+// with errors.Is.
+type ConcurrencyConflictError struct {
+	AggregateID     AggregateID
+	AggregateType   AggregateType
+	ExpectedVersion AggregateVersion
+	ActualVersion   AggregateVersion
+}
+
+func (e *ConcurrencyConflictError) Error() string {
+	return fmt.Sprintf("%s: aggregate '%s' of type '%s' expected at version %d but is at version %d", ErrConcurrencyConflict, e.AggregateID, e.AggregateType, e.ExpectedVersion, e.ActualVersion)
+}
+
+func (e *ConcurrencyConflictError) Unwrap() error {
+	return ErrConcurrencyConflict
+}
+
+// VersionExpectation tells Save how to check the current version of the
+// aggregate stream before appending the changes.
+type VersionExpectation int
+
+const (
+	// ExpectImplicitVersion expects the stream to be at the version preceding
+	// the first change of the aggregate. The stores do not query the current
+	// version for it: the changes conflicting with stored versions are
+	// rejected when they are inserted.
+	ExpectImplicitVersion VersionExpectation = iota
+	// ExpectAnyVersion does not check the version of the stream.
+	ExpectAnyVersion
+	// ExpectExactVersion expects the stream to be at SaveOptions.ExpectedVersion.
+	ExpectExactVersion
+)
+
 type SaveOption func(*SaveOptions)
 
 func WithSnapshot(frequency int) SaveOption {
@@ -25,6 +63,40 @@ func WithSnapshot(frequency int) SaveOption {
 	}
 }
 
+// WithSnapshotStrategy makes Save snapshot the versions selected by the
+// strategy instead of every WithSnapshotFrequency versions. A nil strategy
+// disables snapshots.
+func WithSnapshotStrategy(strategy SnapshotStrategy) SaveOption {
+	return func(opt *SaveOptions) {
+		opt.WithSnapshot = strategy != nil
+		opt.SnapshotStrategy = strategy
+	}
+}
+
+// ExpectVersion makes Save fail with a ConcurrencyConflictError unless the
+// aggregate stream is at the given version.
+func ExpectVersion(version AggregateVersion) SaveOption {
+	return func(opt *SaveOptions) {
+		opt.Expectation = ExpectExactVersion
+		opt.ExpectedVersion = version
+	}
+}
+
+// ExpectNoStream makes Save fail with a ConcurrencyConflictError when the
+// aggregate already has events.
+func ExpectNoStream() SaveOption {
+	return ExpectVersion(0)
+}
+
+// ExpectAny disables the version check of Save. Changes conflicting with
+// stored events are still rejected.
+func ExpectAny() SaveOption {
+	return func(opt *SaveOptions) {
+		opt.Expectation = ExpectAnyVersion
+		opt.ExpectedVersion = 0
+	}
+}
+
 func NewSaveOptions(opts ...SaveOption) *SaveOptions {
 	const (
 		defaultWithSnapshot          = true
@@ -46,15 +118,221 @@ func NewSaveOptions(opts ...SaveOption) *SaveOptions {
 type SaveOptions struct {
 	WithSnapshot          bool
 	WithSnapshotFrequency int
+	SnapshotStrategy      SnapshotStrategy
+	Expectation           VersionExpectation
+	ExpectedVersion       AggregateVersion
+}
+
+// Strategy returns the snapshot strategy of the save: the one of the options,
+// else the one of the aggregate when it is a SnapshotStrategist, else every
+// WithSnapshotFrequency versions.
+func (o *SaveOptions) Strategy(a Aggregate) SnapshotStrategy {
+	if !o.WithSnapshot {
+		return NeverSnapshot()
+	}
+
+	if o.SnapshotStrategy != nil {
+		return o.SnapshotStrategy
+	}
+
+	if s, ok := a.(SnapshotStrategist); ok {
+		if strategy := s.SnapshotStrategy(); strategy != nil {
+			return strategy
+		}
+	}
+
+	return EveryNEvents(o.WithSnapshotFrequency)
+}
+
+// CheckVersion returns a ConcurrencyConflictError when the current version of
+// the aggregate stream does not match the expectation of the options. The
+// stores only call it for ExpectExactVersion.
+func (o *SaveOptions) CheckVersion(a Aggregate, current AggregateVersion) error {
+	expected := o.ExpectedVersion
+
+	switch o.Expectation {
+	case ExpectAnyVersion:
+		return nil
+	case ExpectImplicitVersion:
+		changes := a.Changes()
+		if len(changes) == 0 {
+			return nil
+		}
+
+		expected = changes[0].AggregateVersion() - 1
+	}
+
+	if current != expected {
+		return &ConcurrencyConflictError{
+			AggregateID:     a.ID(),
+			AggregateType:   a.Type(),
+			ExpectedVersion: expected,
+			ActualVersion:   current,
+		}
+	}
+
+	return nil
+}
+
+type ReadOption func(*ReadOptions)
+
+// WithAggregateTypes restricts the events read to the given aggregate types.
+func WithAggregateTypes(types ...AggregateType) ReadOption {
+	return func(opt *ReadOptions) {
+		opt.AggregateTypes = append(opt.AggregateTypes, types...)
+	}
+}
+
+// WithEventTypes restricts the events read to the given event types.
+func WithEventTypes(types ...EventType) ReadOption {
+	return func(opt *ReadOptions) {
+		opt.EventTypes = append(opt.EventTypes, types...)
+	}
+}
+
+// WithBatchSize sets the maximum number of events returned by a read.
+func WithBatchSize(size int) ReadOption {
+	return func(opt *ReadOptions) {
+		if size > 0 {
+			opt.BatchSize = size
+		}
+	}
+}
+
+func NewReadOptions(opts ...ReadOption) *ReadOptions {
+	const (
+		defaultBatchSize = 100
+	)
+
+	result := &ReadOptions{
+		BatchSize: defaultBatchSize,
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+type ReadOptions struct {
+	AggregateTypes []AggregateType
+	EventTypes     []EventType
+	BatchSize      int
+}
+
+// Matches reports whether the event satisfies the type filters of the options.
+func (o *ReadOptions) Matches(e EventReadModel) bool {
+	return containsOrEmpty(o.AggregateTypes, e.AggregateType) && containsOrEmpty(o.EventTypes, e.Type)
+}
+
+func containsOrEmpty[T comparable](values []T, v T) bool {
+	if len(values) == 0 {
+		return true
+	}
+
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadTarget is the point of the stream of an aggregate LoadAt loads it at.
+// Its zero value is the current version.
+type LoadTarget struct {
+	// Version is the last version replayed, or 0 for no bound.
+	Version AggregateVersion
+	// Time excludes the events that occurred after it, or the zero time for
+	// no bound.
+	Time time.Time
+}
+
+// AtVersion makes LoadAt replay the events of the aggregate up to the version.
+func AtVersion(version AggregateVersion) LoadTarget {
+	return LoadTarget{Version: version}
+}
+
+// AtTime makes LoadAt replay the events of the aggregate up to the last one
+// that occurred at or before t.
+func AtTime(t time.Time) LoadTarget {
+	return LoadTarget{Time: t.UTC()}
+}
+
+// Bound returns the last version of the target given the current version of
+// the stream and, when the target has a Time, the last version that occurred
+// at or before it.
+func (t LoadTarget) Bound(current, occurred AggregateVersion) AggregateVersion {
+	bound := current
+	if !t.Time.IsZero() && occurred < bound {
+		bound = occurred
+	}
+
+	if t.Version != 0 && t.Version < bound {
+		bound = t.Version
+	}
+
+	return bound
+}
+
+// LoadResult is the outcome of the load of one of the aggregates of LoadMany.
+type LoadResult struct {
+	Aggregate Aggregate
+	Err       error
 }
 
 type EventStore interface {
 	Save(ctx context.Context, tx Transaction, a Aggregate, opts ...SaveOption) error
 	Load(ctx context.Context, tx Transaction, a Aggregate) (Aggregate, error)
+	// LoadAt loads the aggregate as it was at the target, from the nearest
+	// snapshot at or before it, or returns ErrAggregateDoNotExist when no
+	// event precedes it.
+	LoadAt(ctx context.Context, tx Transaction, a Aggregate, target LoadTarget) (Aggregate, error)
+	// LoadMany loads the aggregates with set-based queries and returns their
+	// results in order. Failures to load an aggregate, such as
+	// ErrAggregateDoNotExist, are reported in its result; the error is only
+	// returned when the batch as a whole fails.
+	LoadMany(ctx context.Context, tx Transaction, aggregates ...Aggregate) ([]LoadResult, error)
 	EventsHistory(ctx context.Context, tx Transaction, aggregateID, aggregateType string, fromVersion int, limit int) ([]EventReadModel, error)
+	// ReadAll returns the next batch of events of the global stream following
+	// the event at fromPosition, the position of the last event read or 0.
+	// Events are returned in an order that later commits cannot go back on,
+	// so that resuming from the last event read skips none; positions are
+	// not always increasing in that order.
+	ReadAll(ctx context.Context, tx Transaction, fromPosition int64, opts ...ReadOption) ([]EventReadModel, error)
 }
 
 type Transaction interface {
 	Commit() error
 	Rollback() error
 }
+
+// TransactionWrapper is implemented by transactions decorating another one,
+// so that event stores can reach the transaction of their driver.
+type TransactionWrapper interface {
+	Transaction
+	Unwrap() Transaction
+}
+
+// TransactionAs returns the first transaction of the chain of wrapped
+// transactions starting at t that is a T.
+func TransactionAs[T any](t Transaction) (T, bool) {
+	for t != nil {
+		if target, ok := t.(T); ok {
+			return target, true
+		}
+
+		w, ok := t.(TransactionWrapper)
+		if !ok {
+			break
+		}
+
+		t = w.Unwrap()
+	}
+
+	var zero T
+
+	return zero, false
+}
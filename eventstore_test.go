@@ -1,8 +1,10 @@
 package eventsource
 
 import (
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestWithSnapshot(t *testing.T) {
@@ -40,3 +42,37 @@ func TestWithSnapshot(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveOptions_CheckVersion(t *testing.T) {
+	a := InitAggregate("agg_1", "test")
+	a.SetVersion(3)
+	a.StackChange(&renamed{BaseEvent: initBaseEvent("evt_1", time.Now(), a.ID(), a.Type(), 4, nil)})
+
+	tests := []struct {
+		name    string
+		opts    []SaveOption
+		current AggregateVersion
+		wantErr bool
+	}{
+		{name: "implicit version matches", current: 3},
+		{name: "implicit version conflicts", current: 4, wantErr: true},
+		{name: "exact version matches", opts: []SaveOption{ExpectVersion(5)}, current: 5},
+		{name: "exact version conflicts", opts: []SaveOption{ExpectVersion(5)}, current: 3, wantErr: true},
+		{name: "no stream", opts: []SaveOption{ExpectNoStream()}, current: 0},
+		{name: "no stream conflicts", opts: []SaveOption{ExpectNoStream()}, current: 1, wantErr: true},
+		{name: "any version", opts: []SaveOption{ExpectAny()}, current: 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewSaveOptions(tt.opts...).CheckVersion(a, tt.current)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var conflict *ConcurrencyConflictError
+			if tt.wantErr && (!errors.As(err, &conflict) || conflict.ActualVersion != tt.current || !errors.Is(err, ErrConcurrencyConflict)) {
+				t.Errorf("CheckVersion() error = %v, want a ConcurrencyConflictError at version %d", err, tt.current)
+			}
+		})
+	}
+}
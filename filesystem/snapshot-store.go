@@ -0,0 +1,207 @@
+// Package filesystem keeps the snapshots of the aggregates as files in a
+// directory, e.g. a mounted blob storage, out of the event store's database.
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+const fileExtension = ".json"
+
+// NewSnapshotStore returns an eventsource.SnapshotStore keeping each snapshot
+// in a file of dir, named after its version in a directory per aggregate. The
+// files are written atomically but do not take part in the transactions of the
+// event store, which ignores the snapshots whose event was rolled back.
+func NewSnapshotStore(dir string) (eventsource.SnapshotStore, error) {
+	if dir == "" {
+		return nil, errors.New("snapshot directory must be specified")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create snapshot directory: %w", err)
+	}
+
+	return &snapshotStore{dir: dir}, nil
+}
+
+type snapshotStore struct {
+	dir string
+}
+
+// file is the content of a snapshot file.
+type file struct {
+	AggregateID      string    `json:"aggregate_id"`
+	AggregateType    string    `json:"aggregate_type"`
+	AggregateVersion int64     `json:"aggregate_version"`
+	SchemaVersion    int       `json:"schema_version"`
+	EventID          string    `json:"event_id,omitempty"`
+	TakenAt          time.Time `json:"taken_at"`
+	Data             []byte    `json:"data"`
+}
+
+func (s *snapshotStore) SaveSnapshot(_ context.Context, _ eventsource.Transaction, snapshot eventsource.Snapshot) error {
+	b, err := json.Marshal(file{
+		AggregateID:      snapshot.AggregateID.String(),
+		AggregateType:    snapshot.AggregateType.String(),
+		AggregateVersion: snapshot.AggregateVersion.Int64(),
+		SchemaVersion:    eventsource.NormalizeSnapshotVersion(snapshot.SchemaVersion),
+		EventID:          snapshot.EventID.String(),
+		TakenAt:          snapshot.TakenAt,
+		Data:             snapshot.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := s.aggregateDir(snapshot.AggregateID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, fileName(snapshot.AggregateVersion)))
+}
+
+func (s *snapshotStore) LatestSnapshot(ctx context.Context, t eventsource.Transaction, id eventsource.AggregateID, schemaVersion int) (*eventsource.Snapshot, error) {
+	return s.SnapshotAt(ctx, t, id, 0, schemaVersion)
+}
+
+func (s *snapshotStore) SnapshotAt(_ context.Context, _ eventsource.Transaction, id eventsource.AggregateID, version eventsource.AggregateVersion, schemaVersion int) (*eventsource.Snapshot, error) {
+	versions, err := s.versions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if version != 0 && versions[i] > version {
+			continue
+		}
+
+		snapshot, err := s.read(id, versions[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if schemaVersion != 0 && snapshot.SchemaVersion != schemaVersion {
+			continue
+		}
+
+		return snapshot, nil
+	}
+
+	return nil, eventsource.ErrNoSnapshotFound
+}
+
+func (s *snapshotStore) DeleteSnapshots(_ context.Context, _ eventsource.Transaction, id eventsource.AggregateID, versions ...eventsource.AggregateVersion) error {
+	if len(versions) == 0 {
+		return os.RemoveAll(s.aggregateDir(id))
+	}
+
+	for _, v := range versions {
+		if err := os.Remove(filepath.Join(s.aggregateDir(id), fileName(v))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// versions returns the versions of the snapshots of the aggregate in
+// ascending order.
+func (s *snapshotStore) versions(id eventsource.AggregateID) ([]eventsource.AggregateVersion, error) {
+	entries, err := os.ReadDir(s.aggregateDir(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]eventsource.AggregateVersion, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileExtension) {
+			continue
+		}
+
+		v, err := strconv.ParseInt(strings.TrimSuffix(name, fileExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, eventsource.AggregateVersion(v))
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+
+	return versions, nil
+}
+
+func (s *snapshotStore) read(id eventsource.AggregateID, version eventsource.AggregateVersion) (*eventsource.Snapshot, error) {
+	b, err := os.ReadFile(filepath.Join(s.aggregateDir(id), fileName(version)))
+	if err != nil {
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("could not decode snapshot %d of aggregate %s: %w", version, id, err)
+	}
+
+	return &eventsource.Snapshot{
+		AggregateID:      eventsource.AggregateID(f.AggregateID),
+		AggregateType:    eventsource.AggregateType(f.AggregateType),
+		AggregateVersion: eventsource.AggregateVersion(f.AggregateVersion),
+		SchemaVersion:    eventsource.NormalizeSnapshotVersion(f.SchemaVersion),
+		EventID:          eventsource.EventID(f.EventID),
+		TakenAt:          f.TakenAt,
+		Data:             f.Data,
+	}, nil
+}
+
+// aggregateDir escapes the id, dots included so that ids such as ".." stay
+// within the directory of the store.
+func (s *snapshotStore) aggregateDir(id eventsource.AggregateID) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(url.PathEscape(id.String()), ".", "%2E"))
+}
+
+// fileName pads the version so that the files of an aggregate list in order.
+func fileName(version eventsource.AggregateVersion) string {
+	return fmt.Sprintf("%020d%s", version.Int64(), fileExtension)
+}
@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+func snapshotOf(id string, version eventsource.AggregateVersion, schemaVersion int) eventsource.Snapshot {
+	return eventsource.Snapshot{
+		AggregateID:      eventsource.AggregateID(id),
+		AggregateType:    "counter",
+		AggregateVersion: version,
+		SchemaVersion:    schemaVersion,
+		TakenAt:          time.Date(2024, 1, 1, 0, 0, int(version), 0, time.UTC),
+		Data:             []byte(`{"value":1}`),
+	}
+}
+
+func TestSnapshotStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewSnapshotStore(dir)
+	if err != nil {
+		t.Fatalf("NewSnapshotStore() error = %v", err)
+	}
+
+	for _, s := range []eventsource.Snapshot{
+		snapshotOf("c1", 5, 1),
+		snapshotOf("c1", 10, 1),
+		snapshotOf("c1", 15, 2),
+		snapshotOf("c2", 3, 1),
+	} {
+		if err := store.SaveSnapshot(ctx, nil, s); err != nil {
+			t.Fatalf("SaveSnapshot() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		version       eventsource.AggregateVersion
+		schemaVersion int
+		want          eventsource.AggregateVersion
+	}{
+		{name: "latest", want: 15},
+		{name: "latest of schema", schemaVersion: 1, want: 10},
+		{name: "at version", version: 12, want: 10},
+		{name: "at exact version", version: 5, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.SnapshotAt(ctx, nil, "c1", tt.version, tt.schemaVersion)
+			if err != nil {
+				t.Fatalf("SnapshotAt() error = %v", err)
+			}
+
+			want := snapshotOf("c1", tt.want, got.SchemaVersion)
+			if got.AggregateVersion != want.AggregateVersion || !got.TakenAt.Equal(want.TakenAt) || string(got.Data) != string(want.Data) {
+				t.Errorf("SnapshotAt() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := store.SnapshotAt(ctx, nil, "c1", 4, 0); !eventsource.ErrIsSnapshotNotFound(err) {
+		t.Errorf("SnapshotAt() before the first snapshot error = %v, want ErrNoSnapshotFound", err)
+	}
+
+	if err := store.DeleteSnapshots(ctx, nil, "c1", 15); err != nil {
+		t.Fatalf("DeleteSnapshots() error = %v", err)
+	}
+
+	if got, err := store.LatestSnapshot(ctx, nil, "c1", 0); err != nil || got.AggregateVersion != 10 {
+		t.Errorf("LatestSnapshot() after delete = %v, %v, want version 10", got, err)
+	}
+
+	if err := store.DeleteSnapshots(ctx, nil, "c1"); err != nil {
+		t.Fatalf("DeleteSnapshots() error = %v", err)
+	}
+
+	if _, err := store.LatestSnapshot(ctx, nil, "c1", 0); !eventsource.ErrIsSnapshotNotFound(err) {
+		t.Errorf("LatestSnapshot() after delete all error = %v, want ErrNoSnapshotFound", err)
+	}
+
+	if got, err := store.LatestSnapshot(ctx, nil, "c2", 0); err != nil || got.AggregateVersion != 3 {
+		t.Errorf("LatestSnapshot() of another aggregate = %v, %v, want version 3", got, err)
+	}
+}
+
+func TestSnapshotStore_EscapesIDs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewSnapshotStore(filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatalf("NewSnapshotStore() error = %v", err)
+	}
+
+	if err := store.SaveSnapshot(ctx, nil, snapshotOf("..", 1, 1)); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+
+	if err := store.SaveSnapshot(ctx, nil, snapshotOf("a/b", 1, 1)); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("ReadDir() = %d entries, want the snapshots directory only", len(entries))
+	}
+
+	if got, err := store.LatestSnapshot(ctx, nil, "a/b", 0); err != nil || got.AggregateID != "a/b" {
+		t.Errorf("LatestSnapshot() = %v, %v, want the snapshot of a/b", got, err)
+	}
+}
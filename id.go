@@ -30,3 +30,17 @@ func (id EventID) IsZero() bool {
 func (id EventID) String() string {
 	return string(id)
 }
+
+func NewMessageID() MessageID {
+	return MessageID(fmt.Sprintf("msg_%s", ksuid.New().String()))
+}
+
+type MessageID string
+
+func (id MessageID) IsZero() bool {
+	return len(strings.TrimSpace(id.String())) == 0
+}
+
+func (id MessageID) String() string {
+	return string(id)
+}
@@ -0,0 +1,171 @@
+package eventsource
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Field is a structured field attached to a log entry.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Logger receives the log entries of the package and of the stores. Enabled
+// is checked before the fields of an entry are built, so that disabled levels
+// cost nothing when replaying long streams.
+type Logger interface {
+	Enabled(ctx context.Context, level Level) bool
+	Log(ctx context.Context, level Level, msg string, fields ...Field)
+}
+
+// NopLogger returns a Logger discarding every entry. It is the default logger.
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Enabled(context.Context, Level) bool {
+	return false
+}
+
+func (nopLogger) Log(context.Context, Level, string, ...Field) {}
+
+// NewSlogLogger returns a Logger writing to l.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &slogLogger{logger: l}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) Enabled(ctx context.Context, level Level) bool {
+	return l.logger.Enabled(ctx, slogLevel(level))
+}
+
+func (l *slogLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.Key, f.Value))
+	}
+
+	l.logger.LogAttrs(ctx, slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type loggerHolder struct {
+	logger Logger
+}
+
+var defaultLogger atomic.Value
+
+func init() {
+	defaultLogger.Store(loggerHolder{logger: NopLogger()})
+}
+
+// SetLogger replaces the global logger, used when the context carries none.
+// A nil logger restores the no-op default.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = NopLogger()
+	}
+
+	defaultLogger.Store(loggerHolder{logger: l})
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a context carrying l, which takes precedence over
+// the global logger. The stores use it to apply their own logger to the
+// aggregates they load.
+func ContextWithLogger(ctx context.Context, l Logger) context.Context {
+	if l == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or the global logger.
+func LoggerFromContext(ctx context.Context) Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey{}).(Logger); ok {
+			return l
+		}
+	}
+
+	return defaultLogger.Load().(loggerHolder).logger
+}
+
+// EventFields returns the structured fields identifying an event.
+func EventFields(e Event) []Field {
+	return []Field{
+		{Key: "aggregate_id", Value: e.AggregateID().String()},
+		{Key: "aggregate_type", Value: e.AggregateType().String()},
+		{Key: "aggregate_version", Value: e.AggregateVersion().Int64()},
+		{Key: "event_id", Value: e.ID().String()},
+		{Key: "event_type", Value: e.Type().String()},
+	}
+}
+
+// EventReadModelFields returns the structured fields identifying a stored
+// event, like EventFields.
+func EventReadModelFields(e EventReadModel) []Field {
+	return []Field{
+		{Key: "aggregate_id", Value: e.AggregateID.String()},
+		{Key: "aggregate_type", Value: e.AggregateType.String()},
+		{Key: "aggregate_version", Value: e.AggregateVersion.Int64()},
+		{Key: "event_id", Value: e.ID.String()},
+		{Key: "event_type", Value: e.Type.String()},
+	}
+}
+
+// AggregateFields returns the structured fields identifying an aggregate at
+// its current version.
+func AggregateFields(a Aggregate) []Field {
+	return []Field{
+		{Key: "aggregate_id", Value: a.ID().String()},
+		{Key: "aggregate_type", Value: a.Type().String()},
+		{Key: "aggregate_version", Value: a.Version().Int64()},
+	}
+}
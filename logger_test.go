@@ -0,0 +1,56 @@
+package eventsource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogger_Raise(t *testing.T) {
+	b := bytes.Buffer{}
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	ctx := ContextWithLogger(context.Background(), logger)
+
+	a := InitAggregate("agg_1", "account")
+	Raise(ctx, a, &renamed{BaseEvent: NewBaseEvent(a, nil), Name: "first"})
+
+	var entry map[string]any
+	if err := json.Unmarshal(b.Bytes(), &entry); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	want := map[string]any{
+		"level":             "DEBUG",
+		"msg":               "event raised",
+		"aggregate_id":      "agg_1",
+		"aggregate_type":    "account",
+		"aggregate_version": float64(1),
+		"event_type":        "renamed",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("entry[%q] = %v, want %v", k, entry[k], v)
+		}
+	}
+}
+
+func TestLogger_Default(t *testing.T) {
+	if LoggerFromContext(context.Background()).Enabled(context.Background(), LevelError) {
+		t.Errorf("default logger is enabled, want no-op")
+	}
+
+	b := bytes.Buffer{}
+	SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(&b, nil))))
+	defer SetLogger(nil)
+
+	LoggerFromContext(context.Background()).Log(context.Background(), LevelDebug, "discarded")
+	LoggerFromContext(context.Background()).Log(context.Background(), LevelInfo, "kept")
+
+	if got := b.String(); !strings.Contains(got, "msg=kept") || strings.Contains(got, "discarded") {
+		t.Errorf("global logger wrote %q, want only the info entry", got)
+	}
+}
@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+func TestEventBus(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	bus := eventsource.NewEventBus()
+	store := eventsource.NewPublishingEventStore(NewEventStore(db))
+	begin := bus.BeginFunc(db.Begin)
+
+	var all, increments, others []eventsource.EventReadModel
+
+	bus.Subscribe(func(_ context.Context, e eventsource.EventReadModel) error {
+		all = append(all, e)
+
+		return nil
+	})
+	unsubscribe := bus.Subscribe(func(_ context.Context, e eventsource.EventReadModel) error {
+		increments = append(increments, e)
+
+		return nil
+	}, eventsource.WithAggregateTypes(counterType), eventsource.WithEventTypes(incrementedType))
+	bus.Subscribe(func(_ context.Context, e eventsource.EventReadModel) error {
+		others = append(others, e)
+
+		return nil
+	}, eventsource.WithAggregateTypes("other"))
+
+	// save increments the counter n times through the bus, then commits or
+	// rolls back.
+	save := func(t *testing.T, id string, n int, commit bool) {
+		t.Helper()
+
+		tx, err := begin(ctx)
+		if err != nil {
+			t.Fatalf("begin() error = %v", err)
+		}
+
+		c := newCounter(id)
+		if _, err := store.Load(ctx, tx, c); err != nil && !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		for i := 0; i < n; i++ {
+			if err := c.Increment(ctx, 1); err != nil {
+				t.Fatalf("Increment() error = %v", err)
+			}
+		}
+
+		if err := store.Save(ctx, tx, c); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		if len(all) != 0 || len(increments) != 0 {
+			t.Fatalf("events published before the end of the transaction")
+		}
+
+		if !commit {
+			if err := tx.Rollback(); err != nil {
+				t.Fatalf("Rollback() error = %v", err)
+			}
+
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+	}
+
+	save(t, "c1", 2, false)
+
+	if len(all) != 0 {
+		t.Fatalf("Rollback() published %d events, want 0", len(all))
+	}
+
+	save(t, "c1", 3, true)
+
+	if len(all) != 3 || len(increments) != 3 || len(others) != 0 {
+		t.Fatalf("Commit() published %d, %d and %d events, want 3, 3 and 0", len(all), len(increments), len(others))
+	}
+
+	for i, e := range increments {
+		if e.AggregateID != "c1" || e.AggregateVersion.Int() != i+1 || string(e.Data) != `{"by":1}` {
+			t.Errorf("event %d = %s v%d %s, want c1 v%d {\"by\":1}", i, e.AggregateID, e.AggregateVersion, e.Data, i+1)
+		}
+	}
+
+	all, increments = nil, nil
+	unsubscribe()
+
+	save(t, "c2", 1, true)
+
+	if len(all) != 1 || len(increments) != 0 {
+		t.Errorf("Commit() after unsubscribe published %d and %d events, want 1 and 0", len(all), len(increments))
+	}
+
+	// Saves with a transaction that is not wrapped by the bus are not
+	// published.
+	saveIncrements(t, db, store, "c3", 1)
+
+	if len(all) != 1 {
+		t.Errorf("Save() without the bus published %d events, want 0", len(all)-1)
+	}
+}
+
+func TestEventBus_PanickingHandler(t *testing.T) {
+	b := bytes.Buffer{}
+	logger := eventsource.NewSlogLogger(slog.New(slog.NewJSONHandler(&b, nil)))
+
+	ctx := eventsource.ContextWithLogger(context.Background(), logger)
+	db := NewDB()
+	bus := eventsource.NewEventBus()
+	store := eventsource.NewPublishingEventStore(NewEventStore(db))
+
+	var handled []eventsource.EventReadModel
+
+	bus.Subscribe(func(context.Context, eventsource.EventReadModel) error {
+		panic("boom")
+	})
+	bus.Subscribe(func(_ context.Context, e eventsource.EventReadModel) error {
+		handled = append(handled, e)
+
+		return nil
+	})
+
+	tx, err := bus.BeginFunc(db.Begin)(ctx)
+	if err != nil {
+		t.Fatalf("begin() error = %v", err)
+	}
+
+	c := newCounter("c1")
+	if err := c.Increment(ctx, 1); err != nil {
+		t.Fatalf("Increment() error = %v", err)
+	}
+
+	if err := store.Save(ctx, tx, c); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	if len(handled) != 1 {
+		t.Errorf("Commit() published %d events to the other handler, want 1", len(handled))
+	}
+
+	if !strings.Contains(b.String(), `"level":"ERROR"`) || !strings.Contains(b.String(), "event handler panicked: boom") {
+		t.Errorf("Commit() logged %q, want the panic logged as an error", b.String())
+	}
+}
@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+// countingStore counts the loads reaching the event store.
+type countingStore struct {
+	eventsource.EventStore
+	loads int
+}
+
+func (s *countingStore) Load(ctx context.Context, tx eventsource.Transaction, a eventsource.Aggregate) (eventsource.Aggregate, error) {
+	s.loads++
+
+	return s.EventStore.Load(ctx, tx, a)
+}
+
+func TestCachingEventStore(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+	counting := &countingStore{EventStore: store}
+	cache := eventsource.NewCachingEventStore(counting)
+
+	load := func(t *testing.T) *counter {
+		t.Helper()
+
+		tx, _ := db.Begin(ctx)
+		defer tx.Rollback()
+
+		loaded, err := cache.Load(ctx, tx, newCounter("c1"))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		return loaded.(*counter)
+	}
+
+	saveIncrements(t, db, store, "c1", 12)
+
+	if c := load(t); c.Value != 12 || counting.loads != 1 || cache.Len() != 1 {
+		t.Fatalf("Load() = value %d after %d loads with %d cached, want 12 after 1 load with 1 cached", c.Value, counting.loads, cache.Len())
+	}
+
+	c := load(t)
+	c.Value = 999
+
+	if c := load(t); c.Value != 12 || c.Version() != 12 || counting.loads != 1 {
+		t.Errorf("Load() = value %d at version %d after %d loads, want 12 at version 12 from the cache", c.Value, c.Version(), counting.loads)
+	}
+
+	saveIncrements(t, db, store, "c1", 3)
+
+	if c := load(t); c.Value != 15 || c.Version() != 15 || counting.loads != 1 {
+		t.Errorf("Load() = value %d at version %d after %d loads, want 15 at version 15 from the cache", c.Value, c.Version(), counting.loads)
+	}
+
+	// The state loaded after a rolled back save is not reused.
+	tx, _ := db.Begin(ctx)
+
+	c = newCounter("c1")
+	if _, err := cache.Load(ctx, tx, c); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if err := c.Increment(ctx, 1); err != nil {
+		t.Fatalf("Increment() error = %v", err)
+	}
+
+	if err := cache.Save(ctx, tx, c); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if cache.Len() != 0 {
+		t.Errorf("Len() after Save = %d, want 0", cache.Len())
+	}
+
+	if _, err := cache.Load(ctx, tx, newCounter("c1")); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tx.Rollback()
+
+	if c := load(t); c.Value != 15 || c.Version() != 15 {
+		t.Errorf("Load() after rollback = value %d at version %d, want 15 at version 15", c.Value, c.Version())
+	}
+}
+
+func TestCachingEventStore_Size(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+	cache := eventsource.NewCachingEventStore(store, eventsource.WithCacheSize(1))
+
+	saveIncrements(t, db, store, "c1", 2)
+	saveIncrements(t, db, store, "c2", 2)
+
+	tx, _ := db.Begin(ctx)
+	defer tx.Rollback()
+
+	for _, id := range []string{"c1", "c2"} {
+		if _, err := cache.Load(ctx, tx, newCounter(id)); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+	}
+
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", cache.Len())
+	}
+}
+
+const talliedType eventsource.EventType = "tallied"
+
+// tally does not implement ParseEvents: its events are decoded by a registry.
+type tally struct {
+	*eventsource.BaseAggregate
+	Count int `es:"count"`
+}
+
+type tallied struct {
+	*eventsource.BaseEvent
+}
+
+func (e *tallied) Type() eventsource.EventType {
+	return talliedType
+}
+
+func (e *tallied) ApplyTo(_ context.Context, a eventsource.Aggregate) {
+	a.(*tally).Count++
+}
+
+func TestCachingEventStore_StoreRegistry(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+
+	registry := eventsource.NewRegistry()
+	eventsource.Register(registry, talliedType, func(b *eventsource.BaseEvent) *tallied {
+		return &tallied{BaseEvent: b}
+	})
+
+	store := NewEventStore(db, WithEventRegistry(registry))
+	cache := eventsource.NewCachingEventStore(store)
+
+	save := func(t *testing.T, store eventsource.EventStore, n int) {
+		t.Helper()
+
+		tx, _ := db.Begin(ctx)
+
+		a := &tally{BaseAggregate: eventsource.InitAggregate("t1", "tally")}
+		if _, err := store.Load(ctx, tx, a); err != nil && !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		for i := 0; i < n; i++ {
+			if err := eventsource.Raise(ctx, a, &tallied{BaseEvent: eventsource.NewBaseEvent(a, nil)}); err != nil {
+				t.Fatalf("Raise() error = %v", err)
+			}
+		}
+
+		if err := store.Save(ctx, tx, a); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+	}
+
+	load := func(t *testing.T) *tally {
+		t.Helper()
+
+		tx, _ := db.Begin(ctx)
+		defer tx.Rollback()
+
+		loaded, err := cache.Load(ctx, tx, &tally{BaseAggregate: eventsource.InitAggregate("t1", "tally")})
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		return loaded.(*tally)
+	}
+
+	save(t, cache, 2)
+
+	// The cached aggregate is refreshed without new events, then with an
+	// event saved around the cache, which only the registry decodes.
+	for _, want := range []int{2, 2} {
+		if a := load(t); a.Count != want {
+			t.Fatalf("Load() = count %d, want %d", a.Count, want)
+		}
+	}
+
+	save(t, store, 1)
+
+	if a := load(t); a.Count != 3 || a.Version() != 3 {
+		t.Errorf("Load() = count %d at version %d, want 3 at version 3", a.Count, a.Version())
+	}
+}
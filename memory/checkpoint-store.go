@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+// NewCheckpointStore returns an eventsource.CheckpointStore keeping the
+// checkpoints of the subscriptions in db.
+func NewCheckpointStore(db *DB) eventsource.CheckpointStore {
+	return &checkpointStore{db: db}
+}
+
+type checkpointStore struct {
+	db *DB
+}
+
+func (s *checkpointStore) LoadCheckpoint(_ context.Context, t eventsource.Transaction, name string) (int64, error) {
+	tx, err := transaction(s.db, t)
+	if err != nil {
+		return 0, err
+	}
+
+	position, exists, err := tx.checkpoint(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		return 0, eventsource.ErrNoCheckpointFound
+	}
+
+	return position, nil
+}
+
+func (s *checkpointStore) SaveCheckpoint(_ context.Context, t eventsource.Transaction, name string, position int64) error {
+	tx, err := transaction(s.db, t)
+	if err != nil {
+		return err
+	}
+
+	return tx.saveCheckpoint(name, position)
+}
@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+func TestSubscriber_Subscribe(t *testing.T) {
+	db := NewDB()
+	store := NewEventStore(db)
+	checkpoints := NewCheckpointStore(db)
+
+	saveIncrements(t, db, store, "c1", 3)
+	saveIncrements(t, db, store, "c2", 2)
+
+	subscriber := eventsource.NewSubscriber(store, checkpoints, db.Begin,
+		eventsource.WithPollInterval(time.Millisecond),
+		eventsource.WithReadOptions(eventsource.WithBatchSize(2)),
+	)
+
+	failing := eventsource.EventHandlerFunc(func(ctx context.Context, tx eventsource.Transaction, e eventsource.EventReadModel) error {
+		if e.Position == 2 {
+			return errors.New("boom")
+		}
+
+		return nil
+	})
+
+	if err := subscriber.CatchUp(context.Background(), "counters", 0, failing); err == nil {
+		t.Fatal("CatchUp() error = nil, want the handler error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	positions := make(chan int64)
+	stopped := make(chan error)
+	go func() {
+		stopped <- subscriber.Subscribe(ctx, "counters", 0, eventsource.EventHandlerFunc(func(ctx context.Context, tx eventsource.Transaction, e eventsource.EventReadModel) error {
+			positions <- e.Position
+
+			return nil
+		}))
+	}()
+
+	expectPositions := func(from, to int64) {
+		t.Helper()
+
+		for want := from; want <= to; want++ {
+			select {
+			case got := <-positions:
+				if got != want {
+					t.Fatalf("Subscribe() handled position %d, want %d", got, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Subscribe() did not handle position %d", want)
+			}
+		}
+	}
+
+	expectPositions(1, 5)
+
+	saveIncrements(t, db, store, "c1", 1)
+
+	expectPositions(6, 6)
+
+	cancel()
+
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Errorf("Subscribe() error = %v, want %v", err, context.Canceled)
+	}
+
+	tx, _ := db.Begin(context.Background())
+	defer tx.Rollback()
+
+	position, err := checkpoints.LoadCheckpoint(context.Background(), tx, "counters")
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", err)
+	}
+
+	if position != 6 {
+		t.Errorf("LoadCheckpoint() = %d, want 6", position)
+	}
+}
+
+func TestSubscriber_CatchUp_OverlappingTransactions(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	subscriber := eventsource.NewSubscriber(store, NewCheckpointStore(db), db.Begin)
+
+	var handled []eventsource.EventReadModel
+
+	catchUp := func(t *testing.T) {
+		t.Helper()
+
+		if err := subscriber.CatchUp(ctx, "counters", 0, eventsource.EventHandlerFunc(func(_ context.Context, _ eventsource.Transaction, e eventsource.EventReadModel) error {
+			handled = append(handled, e)
+
+			return nil
+		})); err != nil {
+			t.Fatalf("CatchUp() error = %v", err)
+		}
+	}
+
+	save := func(t *testing.T, tx eventsource.Transaction, id string) {
+		t.Helper()
+
+		c := newCounter(id)
+		c.Increment(ctx, 1)
+
+		if err := store.Save(ctx, tx, c); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	first, _ := db.Begin(ctx)
+	save(t, first, "c1")
+
+	// The second transaction commits a position after the one of the first
+	// transaction while it is still running: its event must not be read
+	// before the first transaction ends, or the checkpoint would skip c1.
+	second, _ := db.Begin(ctx)
+	save(t, second, "c2")
+
+	if err := second.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	catchUp(t)
+
+	if len(handled) != 0 {
+		t.Fatalf("CatchUp() handled %v before the first transaction ended, want none", handled)
+	}
+
+	if err := first.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	catchUp(t)
+
+	if len(handled) != 2 || handled[0].AggregateID != "c1" || handled[1].AggregateID != "c2" {
+		t.Fatalf("CatchUp() handled %v, want the events of c1 then c2", handled)
+	}
+}
@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+const incrementType eventsource.CommandType = "increment"
+
+type increment struct {
+	ID string
+	By int
+}
+
+func (c *increment) Type() eventsource.CommandType {
+	return incrementType
+}
+
+func (c *increment) AggregateID() eventsource.AggregateID {
+	return eventsource.AggregateID(c.ID)
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	// concurrent saves an increment of the aggregate from another transaction
+	// on the first n calls of the handler.
+	var calls, concurrent int
+
+	registry := eventsource.NewCommandRegistry()
+	eventsource.RegisterCommand(registry, incrementType,
+		func(id eventsource.AggregateID) *counter { return newCounter(id.String()) },
+		func(ctx context.Context, c *counter, cmd *increment) error {
+			calls++
+			if calls <= concurrent {
+				saveIncrements(t, db, store, cmd.ID, 1)
+			}
+
+			return c.Increment(ctx, cmd.By)
+		},
+	)
+
+	dispatcher := eventsource.NewDispatcher(store, db.Begin, registry,
+		eventsource.WithMaxRetries(2),
+		eventsource.WithRetryBackoff(func(int) time.Duration { return 0 }),
+	)
+
+	load := func(t *testing.T) *counter {
+		t.Helper()
+
+		tx, _ := db.Begin(ctx)
+		defer tx.Rollback()
+
+		loaded, err := store.Load(ctx, tx, newCounter("c1"))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		return loaded.(*counter)
+	}
+
+	if err := dispatcher.Dispatch(ctx, &increment{ID: "c1", By: 10}); err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+
+	if c := load(t); c.Value != 10 || calls != 1 {
+		t.Errorf("Dispatch() = value %d after %d calls, want 10 after 1 call", c.Value, calls)
+	}
+
+	calls, concurrent = 0, 2
+
+	if err := dispatcher.Dispatch(ctx, &increment{ID: "c1", By: 10}); err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+
+	if c := load(t); c.Value != 22 || calls != 3 {
+		t.Errorf("Dispatch() = value %d after %d calls, want 22 after 3 calls", c.Value, calls)
+	}
+
+	calls, concurrent = 0, 10
+
+	if err := dispatcher.Dispatch(ctx, &increment{ID: "c1", By: 10}); !errors.Is(err, eventsource.ErrConcurrencyConflict) {
+		t.Errorf("Dispatch() error = %v, want ErrConcurrencyConflict", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("Dispatch() called the handler %d times, want 3", calls)
+	}
+
+	if err := dispatcher.Dispatch(ctx, &unknown{}); !errors.Is(err, eventsource.ErrUnknownCommandType) {
+		t.Errorf("Dispatch() error = %v, want ErrUnknownCommandType", err)
+	}
+}
+
+func TestDispatcher_DispatchTx(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	// The first call of the handler saves an increment of the aggregate from
+	// another transaction.
+	var calls int
+
+	registry := eventsource.NewCommandRegistry()
+	eventsource.RegisterCommand(registry, incrementType,
+		func(id eventsource.AggregateID) *counter { return newCounter(id.String()) },
+		func(ctx context.Context, c *counter, cmd *increment) error {
+			calls++
+			if calls == 1 {
+				saveIncrements(t, db, store, cmd.ID, 1)
+			}
+
+			return c.Increment(ctx, cmd.By)
+		},
+	)
+
+	dispatcher := eventsource.NewDispatcher(store, db.Begin, registry,
+		eventsource.WithMaxRetries(2),
+		eventsource.WithRetryBackoff(func(int) time.Duration { return 0 }),
+	)
+
+	tx, _ := db.Begin(ctx)
+
+	if err := dispatcher.DispatchTx(ctx, tx, &increment{ID: "c1", By: 10}); !errors.Is(err, eventsource.ErrConcurrencyConflict) {
+		t.Fatalf("DispatchTx() error = %v, want ErrConcurrencyConflict", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("DispatchTx() called the handler %d times, want 1", calls)
+	}
+
+	tx.Rollback()
+
+	// The caller runs its transaction again.
+	tx, _ = db.Begin(ctx)
+
+	if err := dispatcher.DispatchTx(ctx, tx, &increment{ID: "c1", By: 10}); err != nil {
+		t.Fatalf("DispatchTx() error = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	tx, _ = db.Begin(ctx)
+	defer tx.Rollback()
+
+	loaded, err := store.Load(ctx, tx, newCounter("c1"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if c := loaded.(*counter); c.Value != 11 || calls != 2 {
+		t.Errorf("DispatchTx() = value %d after %d calls, want 11 after 2 calls", c.Value, calls)
+	}
+}
+
+type unknown struct{}
+
+func (c *unknown) Type() eventsource.CommandType {
+	return "unknown"
+}
+
+func (c *unknown) AggregateID() eventsource.AggregateID {
+	return "c1"
+}
@@ -0,0 +1,570 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+var (
+	ErrTransactionDone           = errors.New("transaction has already been committed or rolled back")
+	ErrDuplicateEventID          = errors.New("duplicate event id")
+	ErrDuplicateAggregateVersion = errors.New("duplicate aggregate version")
+)
+
+// NewDB returns an empty in-memory database. Event stores and transactions
+// created from the same DB share its committed state.
+func NewDB() *DB {
+	return &DB{
+		events:      make(map[eventsource.AggregateID][]eventsource.EventReadModel),
+		snapshots:   make(map[eventsource.AggregateID][]eventsource.Snapshot),
+		eventIDs:    make(map[eventsource.EventID]struct{}),
+		checkpoints: make(map[string]int64),
+		running:     make(map[int64]struct{}),
+		txIDs:       make(map[eventsource.EventID]int64),
+	}
+}
+
+type DB struct {
+	mu          sync.RWMutex
+	events      map[eventsource.AggregateID][]eventsource.EventReadModel
+	snapshots   map[eventsource.AggregateID][]eventsource.Snapshot
+	eventIDs    map[eventsource.EventID]struct{}
+	position    int64
+	checkpoints map[string]int64
+	outbox      []eventsource.OutboxMessage
+	// lastTxID is the id given to the last transaction inserting events.
+	lastTxID int64
+	// running are the ids of the transactions inserting events that have
+	// not ended.
+	running map[int64]struct{}
+	// txIDs are the ids of the transactions that committed the events.
+	txIDs map[eventsource.EventID]int64
+}
+
+// OutboxMessages returns the messages committed to the outbox, in order.
+func (db *DB) OutboxMessages() []eventsource.OutboxMessage {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return append([]eventsource.OutboxMessage(nil), db.outbox...)
+}
+
+// Begin starts a transaction. Events and snapshots saved through the
+// transaction are only visible to other transactions once it is committed.
+func (db *DB) Begin(_ context.Context) (eventsource.Transaction, error) {
+	return &Tx{db: db}, nil
+}
+
+func transaction(db *DB, t eventsource.Transaction) (*Tx, error) {
+	if t == nil {
+		return nil, eventsource.ErrTransactionIsRequired
+	}
+
+	tx, ok := eventsource.TransactionAs[*Tx](t)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", eventsource.ErrUnsupportedTransaction, t)
+	}
+
+	if tx.db != db {
+		return nil, errors.New("transaction does not belong to the database")
+	}
+
+	return tx, nil
+}
+
+type Tx struct {
+	mu          sync.Mutex
+	db          *DB
+	events      []eventsource.EventReadModel
+	snapshots   []eventsource.Snapshot
+	checkpoints map[string]int64
+	outbox      []eventsource.OutboxMessage
+	// deletedSnapshots are the committed snapshots deleted by the
+	// transaction.
+	deletedSnapshots map[snapshotKey]struct{}
+	// id is given on the first insert of events, like postgres assigns
+	// transaction ids on the first write.
+	id   int64
+	done bool
+}
+
+type snapshotKey struct {
+	aggregateID      eventsource.AggregateID
+	aggregateVersion eventsource.AggregateVersion
+}
+
+func keyOf(s eventsource.Snapshot) snapshotKey {
+	return snapshotKey{aggregateID: s.AggregateID, aggregateVersion: s.AggregateVersion}
+}
+
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	tx.done = true
+
+	defer func() {
+		tx.events, tx.snapshots, tx.checkpoints, tx.outbox, tx.deletedSnapshots = nil, nil, nil, nil, nil
+	}()
+
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	defer delete(tx.db.running, tx.id)
+
+	for _, e := range tx.events {
+		if err := tx.db.checkEvent(e); err != nil {
+			if errors.Is(err, errDuplicateVersion) {
+				return conflictError(e, currentVersion(tx.db.events[e.AggregateID], e.AggregateID))
+			}
+
+			return err
+		}
+	}
+
+	for _, s := range tx.snapshots {
+		if err := tx.db.checkSnapshot(s); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range tx.events {
+		tx.db.events[e.AggregateID] = append(tx.db.events[e.AggregateID], e)
+		tx.db.eventIDs[e.ID] = struct{}{}
+		tx.db.txIDs[e.ID] = tx.id
+	}
+
+	for key := range tx.deletedSnapshots {
+		kept := tx.db.snapshots[key.aggregateID][:0]
+		for _, s := range tx.db.snapshots[key.aggregateID] {
+			if keyOf(s) != key {
+				kept = append(kept, s)
+			}
+		}
+
+		tx.db.snapshots[key.aggregateID] = kept
+	}
+
+	for _, s := range tx.snapshots {
+		tx.db.snapshots[s.AggregateID] = append(tx.db.snapshots[s.AggregateID], s)
+	}
+
+	for name, position := range tx.checkpoints {
+		tx.db.checkpoints[name] = position
+	}
+
+	tx.db.outbox = append(tx.db.outbox, tx.outbox...)
+
+	return nil
+}
+
+func (tx *Tx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	tx.done = true
+	tx.events, tx.snapshots, tx.checkpoints, tx.outbox, tx.deletedSnapshots = nil, nil, nil, nil, nil
+
+	if tx.id != 0 {
+		tx.db.mu.Lock()
+		delete(tx.db.running, tx.id)
+		tx.db.mu.Unlock()
+	}
+
+	return nil
+}
+
+// insertEvents stages the events and returns them positioned in the global
+// stream.
+func (tx *Tx) insertEvents(ee ...eventsource.EventReadModel) ([]eventsource.EventReadModel, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return nil, ErrTransactionDone
+	}
+
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+
+	staged := make([]eventsource.EventReadModel, 0, len(tx.events)+len(ee))
+	staged = append(staged, tx.events...)
+
+	for _, e := range ee {
+		err := tx.db.checkEvent(e)
+		if err == nil {
+			for _, s := range staged {
+				if err = conflicts(s, e); err != nil {
+					break
+				}
+			}
+		}
+
+		if errors.Is(err, errDuplicateVersion) {
+			current := currentVersion(tx.db.events[e.AggregateID], e.AggregateID)
+			if stagedVersion := currentVersion(staged, e.AggregateID); stagedVersion > current {
+				current = stagedVersion
+			}
+
+			return nil, conflictError(e, current)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		staged = append(staged, e)
+	}
+
+	// Like a database sequence, positions are assigned on insert and are
+	// not reused when the transaction is rolled back.
+	for i := len(tx.events); i < len(staged); i++ {
+		staged[i].Position = atomic.AddInt64(&tx.db.position, 1)
+	}
+
+	if tx.id == 0 {
+		tx.db.lastTxID++
+		tx.id = tx.db.lastTxID
+		tx.db.running[tx.id] = struct{}{}
+	}
+
+	inserted := append([]eventsource.EventReadModel(nil), staged[len(tx.events):]...)
+	tx.events = staged
+
+	return inserted, nil
+}
+
+func (tx *Tx) insertSnapshots(ss ...eventsource.Snapshot) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+
+	staged := make([]eventsource.Snapshot, 0, len(tx.snapshots)+len(ss))
+	staged = append(staged, tx.snapshots...)
+
+	for _, s := range ss {
+		if err := tx.db.checkSnapshot(s); err != nil {
+			return err
+		}
+
+		for _, o := range staged {
+			if o.AggregateID == s.AggregateID && o.AggregateVersion == s.AggregateVersion {
+				return duplicateSnapshotError(s)
+			}
+		}
+
+		staged = append(staged, s)
+	}
+
+	tx.snapshots = staged
+
+	return nil
+}
+
+// aggregateEvents returns the committed events of the aggregate followed by the ones
+// staged in the transaction.
+func (tx *Tx) aggregateEvents(id eventsource.AggregateID) ([]eventsource.EventReadModel, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return nil, ErrTransactionDone
+	}
+
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+
+	results := make([]eventsource.EventReadModel, 0, len(tx.db.events[id]))
+	results = append(results, tx.db.events[id]...)
+
+	for _, e := range tx.events {
+		if e.AggregateID == id {
+			results = append(results, e)
+		}
+	}
+
+	return results, nil
+}
+
+// streamEvents returns the committed events following the event at
+// fromPosition in the order they became visible. Positions are given on
+// insert and may be committed out of order: like the postgres store, only the
+// events of the transactions older than every running one are returned,
+// ordered by transaction then position.
+func (tx *Tx) streamEvents(fromPosition int64) ([]eventsource.EventReadModel, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return nil, ErrTransactionDone
+	}
+
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+
+	horizon := tx.db.lastTxID + 1
+	for id := range tx.db.running {
+		if id < horizon {
+			horizon = id
+		}
+	}
+
+	// from is the event the stream resumes after: the one at fromPosition
+	// or, failing that, the last one positioned before it.
+	from := streamKey{position: fromPosition}
+
+	var last int64
+
+	for _, ee := range tx.db.events {
+		for _, e := range ee {
+			if e.Position <= fromPosition && e.Position > last {
+				last, from.txID = e.Position, tx.db.txIDs[e.ID]
+			}
+		}
+	}
+
+	results := make([]eventsource.EventReadModel, 0)
+
+	for _, ee := range tx.db.events {
+		for _, e := range ee {
+			key := tx.db.streamKeyOf(e)
+			if key.txID < horizon && from.less(key) {
+				results = append(results, e)
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return tx.db.streamKeyOf(results[i]).less(tx.db.streamKeyOf(results[j]))
+	})
+
+	return results, nil
+}
+
+// streamKey orders the events of the global stream.
+type streamKey struct {
+	txID     int64
+	position int64
+}
+
+func (k streamKey) less(o streamKey) bool {
+	if k.txID != o.txID {
+		return k.txID < o.txID
+	}
+
+	return k.position < o.position
+}
+
+func (db *DB) streamKeyOf(e eventsource.EventReadModel) streamKey {
+	return streamKey{txID: db.txIDs[e.ID], position: e.Position}
+}
+
+func (tx *Tx) insertOutboxMessages(mm ...eventsource.OutboxMessage) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	tx.outbox = append(tx.outbox, mm...)
+
+	return nil
+}
+
+// checkpoint returns the checkpoint staged in the transaction or the committed
+// one.
+func (tx *Tx) checkpoint(name string) (int64, bool, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return 0, false, ErrTransactionDone
+	}
+
+	if position, exists := tx.checkpoints[name]; exists {
+		return position, true, nil
+	}
+
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+
+	position, exists := tx.db.checkpoints[name]
+
+	return position, exists, nil
+}
+
+func (tx *Tx) saveCheckpoint(name string, position int64) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return ErrTransactionDone
+	}
+
+	if tx.checkpoints == nil {
+		tx.checkpoints = make(map[string]int64)
+	}
+
+	tx.checkpoints[name] = position
+
+	return nil
+}
+
+// deleteSnapshots deletes the committed and staged snapshots matching the
+// predicate and returns their number.
+func (tx *Tx) deleteSnapshots(match func(eventsource.Snapshot) bool) (int64, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return 0, ErrTransactionDone
+	}
+
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+
+	var deleted int64
+
+	for _, ss := range tx.db.snapshots {
+		for _, s := range ss {
+			if _, exists := tx.deletedSnapshots[keyOf(s)]; exists || !match(s) {
+				continue
+			}
+
+			if tx.deletedSnapshots == nil {
+				tx.deletedSnapshots = make(map[snapshotKey]struct{})
+			}
+
+			tx.deletedSnapshots[keyOf(s)] = struct{}{}
+			deleted++
+		}
+	}
+
+	staged := tx.snapshots[:0]
+	for _, s := range tx.snapshots {
+		if match(s) {
+			deleted++
+
+			continue
+		}
+
+		staged = append(staged, s)
+	}
+
+	tx.snapshots = staged
+
+	return deleted, nil
+}
+
+// aggregateSnapshots returns the committed snapshots of the aggregate
+// followed by the ones staged in the transaction.
+func (tx *Tx) aggregateSnapshots(id eventsource.AggregateID) ([]eventsource.Snapshot, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return nil, ErrTransactionDone
+	}
+
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+
+	results := make([]eventsource.Snapshot, 0, len(tx.db.snapshots[id]))
+	for _, s := range tx.db.snapshots[id] {
+		if _, deleted := tx.deletedSnapshots[keyOf(s)]; !deleted {
+			results = append(results, s)
+		}
+	}
+
+	for _, s := range tx.snapshots {
+		if s.AggregateID == id {
+			results = append(results, s)
+		}
+	}
+
+	return results, nil
+}
+
+func (db *DB) checkEvent(e eventsource.EventReadModel) error {
+	if _, exists := db.eventIDs[e.ID]; exists {
+		return fmt.Errorf("%w: '%s'", ErrDuplicateEventID, e.ID)
+	}
+
+	for _, o := range db.events[e.AggregateID] {
+		if err := conflicts(o, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// errDuplicateVersion is turned into an eventsource.ConcurrencyConflictError
+// by conflictError once the actual version of the stream is known.
+var errDuplicateVersion = errors.New("duplicate version")
+
+func conflictError(e eventsource.EventReadModel, current eventsource.AggregateVersion) error {
+	return &eventsource.ConcurrencyConflictError{
+		AggregateID:     e.AggregateID,
+		AggregateType:   e.AggregateType,
+		ExpectedVersion: e.AggregateVersion - 1,
+		ActualVersion:   current,
+	}
+}
+
+func currentVersion(ee []eventsource.EventReadModel, id eventsource.AggregateID) eventsource.AggregateVersion {
+	var current eventsource.AggregateVersion
+	for _, e := range ee {
+		if e.AggregateID == id && e.AggregateVersion > current {
+			current = e.AggregateVersion
+		}
+	}
+
+	return current
+}
+
+func (db *DB) checkSnapshot(s eventsource.Snapshot) error {
+	for _, o := range db.snapshots[s.AggregateID] {
+		if o.AggregateVersion == s.AggregateVersion {
+			return duplicateSnapshotError(s)
+		}
+	}
+
+	return nil
+}
+
+func conflicts(existing, e eventsource.EventReadModel) error {
+	if existing.ID == e.ID {
+		return fmt.Errorf("%w: '%s'", ErrDuplicateEventID, e.ID)
+	}
+
+	if existing.AggregateID == e.AggregateID && existing.AggregateVersion == e.AggregateVersion {
+		return errDuplicateVersion
+	}
+
+	return nil
+}
+
+func duplicateSnapshotError(s eventsource.Snapshot) error {
+	return fmt.Errorf("%w: snapshot of aggregate '%s' at version %d already exists", ErrDuplicateAggregateVersion, s.AggregateID, s.AggregateVersion)
+}
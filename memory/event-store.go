@@ -0,0 +1,420 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+// NewEventStore returns an eventsource.EventStore keeping its events and
+// snapshots in db. It is meant to be used in unit tests in place of the
+// postgres event store and expects transactions started with db.Begin.
+func NewEventStore(db *DB, opts ...Option) eventsource.EventStore {
+	s := &eventStore{db: db, snapshots: NewSnapshotStore(db)}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type Option func(*eventStore)
+
+// WithEventRegistry makes the event store decode loaded events with the
+// registry instead of the aggregate's ParseEvents.
+func WithEventRegistry(r *eventsource.Registry) Option {
+	return func(s *eventStore) {
+		s.registry = r
+	}
+}
+
+// WithUpcasters makes the event store upcast the events it reads to their
+// latest schema version.
+func WithUpcasters(u *eventsource.Upcasters) Option {
+	return func(s *eventStore) {
+		s.upcasters = u
+	}
+}
+
+// WithInlineProjections makes the event store project the saved events within
+// the transaction of Save.
+func WithInlineProjections(projections ...eventsource.Projection) Option {
+	return func(s *eventStore) {
+		s.projections = append(s.projections, projections...)
+	}
+}
+
+// WithOutbox makes the event store write the messages derived from the saved
+// events by the mapper to the outbox of the database within the transaction of
+// Save.
+func WithOutbox(mapper eventsource.OutboxMapper) Option {
+	return func(s *eventStore) {
+		s.outboxMapper = mapper
+	}
+}
+
+// WithLogger makes the event store log through l rather than the global
+// logger, including while replaying the aggregates it loads.
+func WithLogger(l eventsource.Logger) Option {
+	return func(s *eventStore) {
+		s.logger = l
+	}
+}
+
+// WithSnapshotStore makes the event store keep the snapshots in ss rather than
+// in its database.
+func WithSnapshotStore(ss eventsource.SnapshotStore) Option {
+	return func(s *eventStore) {
+		s.snapshots = ss
+	}
+}
+
+// WithSnapshotRetention makes the event store prune the snapshots of an
+// aggregate that the policy does not keep whenever Save snapshots it. It only
+// applies to the snapshots kept in the database.
+func WithSnapshotRetention(policy eventsource.RetentionPolicy) Option {
+	return func(s *eventStore) {
+		s.retention = &policy
+	}
+}
+
+type eventStore struct {
+	db           *DB
+	logger       eventsource.Logger
+	snapshots    eventsource.SnapshotStore
+	retention    *eventsource.RetentionPolicy
+	registry     *eventsource.Registry
+	upcasters    *eventsource.Upcasters
+	projections  []eventsource.Projection
+	outboxMapper eventsource.OutboxMapper
+}
+
+// Registry returns the registry the events are decoded with, nil when the
+// aggregates parse them.
+func (s *eventStore) Registry() *eventsource.Registry {
+	return s.registry
+}
+
+func (s *eventStore) Save(ctx context.Context, t eventsource.Transaction, a eventsource.Aggregate, opts ...eventsource.SaveOption) error {
+	ctx = eventsource.ContextWithLogger(ctx, s.logger)
+
+	tx, err := s.transaction(t)
+	if err != nil {
+		return err
+	}
+
+	options := eventsource.NewSaveOptions(opts...)
+
+	changes := a.Changes()
+	if len(changes) == 0 {
+		return eventsource.ErrNoEventsToStore
+	}
+
+	if options.Expectation == eventsource.ExpectExactVersion {
+		ee, err := tx.aggregateEvents(a.ID())
+		if err != nil {
+			return err
+		}
+
+		if err := options.CheckVersion(a, currentVersion(ee, a.ID())); err != nil {
+			return err
+		}
+	}
+
+	events := make([]eventsource.EventReadModel, 0, len(changes))
+	for _, e := range changes {
+		event, err := eventsource.NewEventReadModel(e)
+		if err != nil {
+			return err
+		}
+
+		events = append(events, event)
+	}
+
+	events, err = tx.insertEvents(events...)
+	if err != nil {
+		return err
+	}
+
+	if err := eventsource.Project(ctx, t, events, s.projections...); err != nil {
+		return err
+	}
+
+	messages, err := eventsource.MapOutboxMessages(ctx, s.outboxMapper, changes...)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.insertOutboxMessages(messages...); err != nil {
+		return err
+	}
+
+	if options.WithSnapshot {
+		strategy := options.Strategy(a)
+
+		var last *eventsource.Snapshot
+		if eventsource.ReadsLastSnapshot(strategy) {
+			last, err = s.snapshots.LatestSnapshot(ctx, t, a.ID(), 0)
+			if err != nil && !eventsource.ErrIsSnapshotNotFound(err) {
+				return err
+			}
+		}
+
+		snapshot, err := eventsource.TakeSnapshot(ctx, strategy, a, last)
+		if err != nil {
+			return err
+		}
+
+		if snapshot != nil {
+			if err := s.snapshots.SaveSnapshot(ctx, t, *snapshot); err != nil {
+				return err
+			}
+
+			if s.ownsSnapshots() && s.retention != nil {
+				if _, err := pruneSnapshots(tx, *s.retention, a.ID()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	eventsource.LoggerFromContext(ctx).Log(ctx, eventsource.LevelDebug, "events saved",
+		append(eventsource.AggregateFields(a), eventsource.Field{Key: "events", Value: len(changes)})...)
+
+	return nil
+}
+
+func (s *eventStore) EventsHistory(ctx context.Context, t eventsource.Transaction, aggregateID, aggregateType string, fromVersion int, limit int) ([]eventsource.EventReadModel, error) {
+	tx, err := s.transaction(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loadEvents(ctx, tx, eventsource.AggregateID(aggregateID), eventsource.AggregateType(aggregateType), eventsource.AggregateVersion(fromVersion), limit)
+}
+
+func (s *eventStore) Load(ctx context.Context, t eventsource.Transaction, aggregate eventsource.Aggregate) (eventsource.Aggregate, error) {
+	return s.LoadAt(ctx, t, aggregate, eventsource.LoadTarget{})
+}
+
+func (s *eventStore) LoadAt(ctx context.Context, t eventsource.Transaction, aggregate eventsource.Aggregate, target eventsource.LoadTarget) (eventsource.Aggregate, error) {
+	if aggregate.ID().IsZero() || aggregate.Type().IsZero() {
+		return nil, errors.New("aggragate id and type must be specified")
+	}
+
+	ctx = eventsource.ContextWithLogger(ctx, s.logger)
+
+	aggregate.PrepareForLoading()
+
+	tx, err := s.transaction(t)
+	if err != nil {
+		return nil, err
+	}
+
+	bound, err := s.boundOf(tx, aggregate, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if bound == 0 {
+		return nil, eventsource.ErrAggregateDoNotExist
+	}
+
+	snapshot, err := s.loadSnapshot(ctx, t, aggregate, bound)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotExist := false
+	fromVersion := eventsource.AggregateVersion(1)
+
+	if snapshot != nil {
+		fromVersion = snapshot.AggregateVersion.Next()
+		snapshotExist = true
+	}
+
+	ee, err := s.loadEvents(ctx, tx, aggregate.ID(), aggregate.Type(), fromVersion, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range ee {
+		if e.AggregateVersion > bound {
+			ee = ee[:i]
+
+			break
+		}
+	}
+
+	if len(ee) == 0 && !snapshotExist {
+		return nil, eventsource.ErrAggregateDoNotExist
+	}
+
+	events, err := eventsource.ParseEvents(ctx, s.registry, aggregate, ee...)
+	if err != nil {
+		return nil, err
+	}
+
+	return eventsource.Replay(ctx, aggregate, snapshot, events...)
+}
+
+func (s *eventStore) LoadMany(ctx context.Context, t eventsource.Transaction, aggregates ...eventsource.Aggregate) ([]eventsource.LoadResult, error) {
+	if _, err := s.transaction(t); err != nil {
+		return nil, err
+	}
+
+	results := make([]eventsource.LoadResult, 0, len(aggregates))
+	for _, a := range aggregates {
+		loaded, err := s.Load(ctx, t, a)
+		results = append(results, eventsource.LoadResult{Aggregate: loaded, Err: err})
+	}
+
+	return results, nil
+}
+
+// boundOf returns the last version of the aggregate within the target.
+func (s *eventStore) boundOf(tx *Tx, a eventsource.Aggregate, target eventsource.LoadTarget) (eventsource.AggregateVersion, error) {
+	ee, err := tx.aggregateEvents(a.ID())
+	if err != nil {
+		return 0, err
+	}
+
+	var current, occurred eventsource.AggregateVersion
+	for _, e := range ee {
+		if e.AggregateType != a.Type() {
+			continue
+		}
+
+		if e.AggregateVersion > current {
+			current = e.AggregateVersion
+		}
+
+		if !e.OccurredAt.After(target.Time) && e.AggregateVersion > occurred {
+			occurred = e.AggregateVersion
+		}
+	}
+
+	return target.Bound(current, occurred), nil
+}
+
+func (s *eventStore) ReadAll(ctx context.Context, t eventsource.Transaction, fromPosition int64, opts ...eventsource.ReadOption) ([]eventsource.EventReadModel, error) {
+	tx, err := s.transaction(t)
+	if err != nil {
+		return nil, err
+	}
+
+	options := eventsource.NewReadOptions(opts...)
+
+	ee, err := tx.streamEvents(fromPosition)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]eventsource.EventReadModel, 0)
+	for _, e := range ee {
+		if options.Matches(e) {
+			events = append(events, e)
+		}
+	}
+
+	if len(events) > options.BatchSize {
+		events = events[:options.BatchSize]
+	}
+
+	return s.upcasters.UpcastAll(ctx, events...)
+}
+
+func (s *eventStore) transaction(t eventsource.Transaction) (*Tx, error) {
+	return transaction(s.db, t)
+}
+
+func (s *eventStore) loadEvents(ctx context.Context, tx *Tx, id eventsource.AggregateID, aggregateType eventsource.AggregateType, fromVersion eventsource.AggregateVersion, limit int) ([]eventsource.EventReadModel, error) {
+	ee, err := tx.aggregateEvents(id)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]eventsource.EventReadModel, 0)
+	for _, e := range ee {
+		if e.AggregateVersion >= fromVersion && e.AggregateType == aggregateType {
+			events = append(events, e)
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].AggregateVersion < events[j].AggregateVersion
+	})
+
+	if limit != 0 && len(events) > limit {
+		events = events[:limit]
+	}
+
+	return s.upcasters.UpcastAll(ctx, events...)
+}
+
+// PurgeSnapshots deletes the snapshots of the aggregate type taken with
+// another schema version than schemaVersion. It only applies to the snapshots
+// kept in the database.
+func (s *eventStore) PurgeSnapshots(_ context.Context, t eventsource.Transaction, aggregateType eventsource.AggregateType, schemaVersion int) (int64, error) {
+	if !s.ownsSnapshots() {
+		return 0, fmt.Errorf("%w: snapshots are kept in %T", eventsource.ErrSnapshotPurgeNotSupported, s.snapshots)
+	}
+
+	tx, err := s.transaction(t)
+	if err != nil {
+		return 0, err
+	}
+
+	return tx.deleteSnapshots(func(snapshot eventsource.Snapshot) bool {
+		return snapshot.AggregateType == aggregateType && snapshot.SchemaVersion != schemaVersion
+	})
+}
+
+// loadSnapshot returns the latest snapshot of the aggregate at or before the
+// bound compatible with its schema version, or nil. Snapshots of another
+// snapshot store whose event is not the one stored at their version were left
+// by rolled back saves and are skipped.
+func (s *eventStore) loadSnapshot(ctx context.Context, t eventsource.Transaction, a eventsource.Aggregate, bound eventsource.AggregateVersion) (*eventsource.Snapshot, error) {
+	tx, err := s.transaction(t)
+	if err != nil {
+		return nil, err
+	}
+
+	for bound > 0 {
+		snapshot, err := s.snapshots.SnapshotAt(ctx, t, a.ID(), bound, eventsource.SnapshotVersionOf(a))
+		if eventsource.ErrIsSnapshotNotFound(err) {
+			return nil, nil
+		}
+
+		if err != nil || s.ownsSnapshots() || snapshot.EventID.IsZero() {
+			return snapshot, err
+		}
+
+		ee, err := tx.aggregateEvents(a.ID())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range ee {
+			if e.AggregateVersion == snapshot.AggregateVersion && e.ID == snapshot.EventID {
+				return snapshot, nil
+			}
+		}
+
+		bound = snapshot.AggregateVersion - 1
+	}
+
+	return nil, nil
+}
+
+// ownsSnapshots reports whether the snapshots are kept in the database of the
+// event store, within its transactions.
+func (s *eventStore) ownsSnapshots() bool {
+	ss, ok := s.snapshots.(*snapshotStore)
+
+	return ok && ss.db == s.db
+}
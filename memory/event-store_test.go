@@ -0,0 +1,573 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+	"github.com/thefabric-io/eventsource/filesystem"
+)
+
+const counterType eventsource.AggregateType = "counter"
+
+type counter struct {
+	*eventsource.BaseAggregate
+	Value int `es:"value"`
+
+	schemaVersion int
+}
+
+func (c *counter) SnapshotVersion() int {
+	if c.schemaVersion == 0 {
+		return 1
+	}
+
+	return c.schemaVersion
+}
+
+func newCounter(id string) *counter {
+	return &counter{BaseAggregate: eventsource.InitAggregate(id, counterType)}
+}
+
+func (c *counter) Increment(ctx context.Context, by int) error {
+	return eventsource.Raise(ctx, c, &incremented{BaseEvent: eventsource.NewBaseEvent(c, nil), By: by})
+}
+
+func (c *counter) ParseEvents(_ context.Context, ee ...eventsource.EventReadModel) []eventsource.Event {
+	results := make([]eventsource.Event, 0, len(ee))
+	for _, e := range ee {
+		switch e.Type {
+		case incrementedType:
+			event := &incremented{BaseEvent: e.InitBaseEvent()}
+			if err := eventsource.UnmarshalES(e.Data, event); err != nil {
+				continue
+			}
+
+			results = append(results, event)
+		}
+	}
+
+	return results
+}
+
+const incrementedType eventsource.EventType = "incremented"
+
+type incremented struct {
+	*eventsource.BaseEvent
+	By int `es:"by"`
+}
+
+func (e *incremented) Type() eventsource.EventType {
+	return incrementedType
+}
+
+func (e *incremented) ApplyTo(_ context.Context, a eventsource.Aggregate) {
+	a.(*counter).Value += e.By
+}
+
+func saveIncrements(t *testing.T, db *DB, store eventsource.EventStore, id string, n int, opts ...eventsource.SaveOption) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	tx, _ := db.Begin(ctx)
+
+	c := newCounter(id)
+	if _, err := store.Load(ctx, tx, c); err != nil && !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		if err := c.Increment(ctx, 1); err != nil {
+			t.Fatalf("Increment() error = %v", err)
+		}
+	}
+
+	if err := store.Save(ctx, tx, c, opts...); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+}
+
+func TestEventStore_SaveAndLoad(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	saveIncrements(t, db, store, "c1", 12)
+	saveIncrements(t, db, store, "c1", 3)
+
+	tx, _ := db.Begin(ctx)
+	defer tx.Rollback()
+
+	loaded, err := store.Load(ctx, tx, newCounter("c1"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	c := loaded.(*counter)
+	if c.Value != 15 || c.Version() != 15 {
+		t.Errorf("Load() = value %d at version %d, want 15 at version 15", c.Value, c.Version())
+	}
+
+	snapshot, err := NewSnapshotStore(db).LatestSnapshot(ctx, tx, "c1", 1)
+	if err != nil {
+		t.Fatalf("LatestSnapshot() error = %v", err)
+	}
+
+	if snapshot.AggregateVersion != 12 {
+		t.Errorf("LatestSnapshot() version = %d, want 12", snapshot.AggregateVersion)
+	}
+
+	history, err := store.EventsHistory(ctx, tx, "c1", counterType.String(), 14, 0)
+	if err != nil {
+		t.Fatalf("EventsHistory() error = %v", err)
+	}
+
+	if len(history) != 2 || history[0].AggregateVersion != 14 {
+		t.Errorf("EventsHistory() = %v, want versions 14 and 15", history)
+	}
+}
+
+func TestEventStore_LoadAt(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+
+	saveIncrements(t, db, store, "c1", 12)
+
+	between := time.Now()
+	time.Sleep(time.Millisecond)
+
+	saveIncrements(t, db, store, "c1", 3)
+
+	tx, _ := db.Begin(ctx)
+	defer tx.Rollback()
+
+	tests := []struct {
+		name   string
+		target eventsource.LoadTarget
+		want   eventsource.AggregateVersion
+	}{
+		{name: "before the first snapshot", target: eventsource.AtVersion(5), want: 5},
+		{name: "at a snapshot", target: eventsource.AtVersion(12), want: 12},
+		{name: "after a snapshot", target: eventsource.AtVersion(14), want: 14},
+		{name: "beyond the stream", target: eventsource.AtVersion(100), want: 15},
+		{name: "at a time", target: eventsource.AtTime(between), want: 12},
+		{name: "current", want: 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := store.LoadAt(ctx, tx, newCounter("c1"), tt.target)
+			if err != nil {
+				t.Fatalf("LoadAt() error = %v", err)
+			}
+
+			if c := loaded.(*counter); c.Value != int(tt.want) || c.Version() != tt.want {
+				t.Errorf("LoadAt() = value %d at version %d, want %d", c.Value, c.Version(), tt.want)
+			}
+		})
+	}
+
+	if _, err := store.LoadAt(ctx, tx, newCounter("c1"), eventsource.AtTime(before)); !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+		t.Errorf("LoadAt() before the first event error = %v, want ErrAggregateDoNotExist", err)
+	}
+}
+
+func TestEventStore_Errors(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	tx, _ := db.Begin(ctx)
+	defer tx.Rollback()
+
+	if _, err := store.Load(ctx, tx, newCounter("unknown")); !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+		t.Errorf("Load() error = %v, want %v", err, eventsource.ErrAggregateDoNotExist)
+	}
+
+	if err := store.Save(ctx, tx, newCounter("c1")); !errors.Is(err, eventsource.ErrNoEventsToStore) {
+		t.Errorf("Save() error = %v, want %v", err, eventsource.ErrNoEventsToStore)
+	}
+
+	if err := store.Save(ctx, nil, newCounter("c1")); !errors.Is(err, eventsource.ErrTransactionIsRequired) {
+		t.Errorf("Save() error = %v, want %v", err, eventsource.ErrTransactionIsRequired)
+	}
+
+	first, second := newCounter("c1"), newCounter("c1")
+	first.Increment(ctx, 1)
+	second.Increment(ctx, 2)
+
+	if err := store.Save(ctx, tx, first); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	var conflict *eventsource.ConcurrencyConflictError
+	if err := store.Save(ctx, tx, second); !errors.As(err, &conflict) || conflict.ExpectedVersion != 0 || conflict.ActualVersion != 1 {
+		t.Errorf("Save() error = %v, want %v from version 0 to 1", err, eventsource.ErrConcurrencyConflict)
+	}
+
+	if err := store.Save(ctx, tx, second, eventsource.ExpectAny()); !errors.Is(err, eventsource.ErrConcurrencyConflict) {
+		t.Errorf("Save() with ExpectAny() error = %v, want %v", err, eventsource.ErrConcurrencyConflict)
+	}
+}
+
+func TestEventStore_SnapshotSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	saveIncrements(t, db, store, "c1", 12)
+
+	tx, _ := db.Begin(ctx)
+	defer tx.Rollback()
+
+	upgraded := newCounter("c1")
+	upgraded.schemaVersion = 2
+
+	if _, err := store.Load(ctx, tx, upgraded); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if upgraded.Value != 12 || upgraded.Version() != 12 {
+		t.Errorf("Load() = value %d at version %d, want 12 at version 12", upgraded.Value, upgraded.Version())
+	}
+
+	upgraded.Increment(ctx, 1)
+
+	if err := store.Save(ctx, tx, upgraded, eventsource.WithSnapshotStrategy(eventsource.OnStaleSnapshot())); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	purged, err := eventsource.PurgeOutdatedSnapshots(ctx, store, tx, upgraded)
+	if err != nil {
+		t.Fatalf("PurgeOutdatedSnapshots() error = %v", err)
+	}
+
+	if purged != 1 {
+		t.Errorf("PurgeOutdatedSnapshots() = %d, want 1", purged)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	tx, _ = db.Begin(ctx)
+	defer tx.Rollback()
+
+	ss, err := tx.(*Tx).aggregateSnapshots("c1")
+	if err != nil {
+		t.Fatalf("aggregateSnapshots() error = %v", err)
+	}
+
+	if len(ss) != 1 || ss[0].AggregateVersion != 13 || ss[0].SchemaVersion != 2 {
+		t.Errorf("aggregateSnapshots() = %v, want a single snapshot at version 13 with schema version 2", ss)
+	}
+}
+
+func TestEventStore_SnapshotStore(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+
+	snapshots, err := filesystem.NewSnapshotStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewSnapshotStore() error = %v", err)
+	}
+
+	store := NewEventStore(db, WithSnapshotStore(snapshots))
+
+	saveIncrements(t, db, store, "c1", 12)
+
+	snapshot, err := snapshots.LatestSnapshot(ctx, nil, "c1", 1)
+	if err != nil {
+		t.Fatalf("LatestSnapshot() error = %v", err)
+	}
+
+	if snapshot.AggregateVersion != 12 {
+		t.Errorf("LatestSnapshot() version = %d, want 12", snapshot.AggregateVersion)
+	}
+
+	// The snapshot of a rolled back save outlives the transaction.
+	tx, _ := db.Begin(ctx)
+
+	c := newCounter("c1")
+	if _, err := store.Load(ctx, tx, c); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := c.Increment(ctx, 2); err != nil {
+			t.Fatalf("Increment() error = %v", err)
+		}
+	}
+
+	if err := store.Save(ctx, tx, c); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	tx.Rollback()
+
+	tx, _ = db.Begin(ctx)
+	defer tx.Rollback()
+
+	loaded, err := store.Load(ctx, tx, newCounter("c1"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if c := loaded.(*counter); c.Value != 12 || c.Version() != 12 {
+		t.Errorf("Load() = value %d at version %d, want 12 at version 12", c.Value, c.Version())
+	}
+
+	// Once the stream reaches the version of the orphan again, the orphan
+	// is not restored in place of the stored events.
+	tx.Rollback()
+
+	saveIncrements(t, db, store, "c1", 10, eventsource.WithSnapshotStrategy(eventsource.NeverSnapshot()))
+
+	tx, _ = db.Begin(ctx)
+	defer tx.Rollback()
+
+	loaded, err = store.Load(ctx, tx, newCounter("c1"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if c := loaded.(*counter); c.Value != 22 || c.Version() != 22 {
+		t.Errorf("Load() = value %d at version %d, want 22 at version 22", c.Value, c.Version())
+	}
+
+	if _, err := NewSnapshotStore(db).LatestSnapshot(ctx, tx, "c1", 0); !eventsource.ErrIsSnapshotNotFound(err) {
+		t.Errorf("LatestSnapshot() of the database error = %v, want ErrNoSnapshotFound", err)
+	}
+
+	if _, err := store.(eventsource.SnapshotPurger).PurgeSnapshots(ctx, tx, counterType, 1); !errors.Is(err, eventsource.ErrSnapshotPurgeNotSupported) {
+		t.Errorf("PurgeSnapshots() error = %v, want ErrSnapshotPurgeNotSupported", err)
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	every := eventsource.WithSnapshotStrategy(eventsource.EveryNEvents(1))
+
+	retaining := NewEventStore(db, WithSnapshotRetention(eventsource.KeepLast(2)))
+	for i := 0; i < 3; i++ {
+		saveIncrements(t, db, retaining, "c1", 1, every)
+	}
+
+	store := NewEventStore(db)
+	for i := 0; i < 3; i++ {
+		saveIncrements(t, db, store, "c2", 1, every)
+	}
+
+	deleted, err := PruneSnapshots(ctx, db, eventsource.KeepLast(1), eventsource.WithPruneChunkSize(1))
+	if err != nil {
+		t.Fatalf("PruneSnapshots() error = %v", err)
+	}
+
+	if deleted != 3 {
+		t.Errorf("PruneSnapshots() = %d, want 3", deleted)
+	}
+}
+
+func TestTx_Isolation(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	rolledBack, _ := db.Begin(ctx)
+
+	c := newCounter("c1")
+	c.Increment(ctx, 1)
+
+	if err := store.Save(ctx, rolledBack, c); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	other, _ := db.Begin(ctx)
+	if _, err := store.Load(ctx, other, newCounter("c1")); !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+		t.Errorf("Load() from another transaction error = %v, want %v", err, eventsource.ErrAggregateDoNotExist)
+	}
+
+	if err := rolledBack.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+
+	if err := rolledBack.Commit(); !errors.Is(err, ErrTransactionDone) {
+		t.Errorf("Commit() after Rollback() error = %v, want %v", err, ErrTransactionDone)
+	}
+
+	if _, err := store.Load(ctx, other, newCounter("c1")); !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+		t.Errorf("Load() after Rollback() error = %v, want %v", err, eventsource.ErrAggregateDoNotExist)
+	}
+
+	first, _ := db.Begin(ctx)
+	second, _ := db.Begin(ctx)
+
+	for _, tx := range []eventsource.Transaction{first, second} {
+		c := newCounter("c2")
+		c.Increment(ctx, 1)
+
+		if err := store.Save(ctx, tx, c); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	if err := first.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	if err := second.Commit(); !errors.Is(err, eventsource.ErrConcurrencyConflict) {
+		t.Errorf("Commit() error = %v, want %v", err, eventsource.ErrConcurrencyConflict)
+	}
+}
+
+func TestEventStore_ReadAll(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	saveIncrements(t, db, store, "c1", 2)
+	saveIncrements(t, db, store, "c2", 3)
+	saveIncrements(t, db, store, "c1", 1)
+
+	tx, _ := db.Begin(ctx)
+	defer tx.Rollback()
+
+	all, err := store.ReadAll(ctx, tx, 0)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	if len(all) != 6 {
+		t.Fatalf("ReadAll() returned %d events, want 6", len(all))
+	}
+
+	for i, e := range all {
+		if e.Position != int64(i+1) {
+			t.Errorf("ReadAll()[%d].Position = %d, want %d", i, e.Position, i+1)
+		}
+	}
+
+	if all[5].AggregateID != "c1" || all[5].AggregateVersion != 3 {
+		t.Errorf("ReadAll()[5] = %s v%d, want c1 v3", all[5].AggregateID, all[5].AggregateVersion)
+	}
+
+	batch, err := store.ReadAll(ctx, tx, 2, eventsource.WithBatchSize(2), eventsource.WithEventTypes(incrementedType))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	if len(batch) != 2 || batch[0].Position != 3 || batch[1].Position != 4 {
+		t.Errorf("ReadAll() from position 2 = %v, want positions 3 and 4", batch)
+	}
+
+	none, err := store.ReadAll(ctx, tx, 0, eventsource.WithAggregateTypes("unknown"))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	if len(none) != 0 {
+		t.Errorf("ReadAll() of unknown aggregate type returned %d events, want 0", len(none))
+	}
+}
+
+type totals struct {
+	handled []eventsource.EventReadModel
+}
+
+func (p *totals) Name() string {
+	return "totals"
+}
+
+func (p *totals) Handles() []eventsource.EventType {
+	return []eventsource.EventType{incrementedType}
+}
+
+func (p *totals) Project(_ context.Context, _ eventsource.Transaction, e eventsource.EventReadModel) error {
+	p.handled = append(p.handled, e)
+
+	return nil
+}
+
+func TestEventStore_InlineProjections(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	projection := &totals{}
+	store := NewEventStore(db, WithInlineProjections(projection))
+
+	saveIncrements(t, db, store, "c1", 2)
+
+	if len(projection.handled) != 2 {
+		t.Fatalf("Project() called %d times, want 2", len(projection.handled))
+	}
+
+	for i, e := range projection.handled {
+		if e.Position != int64(i+1) || e.AggregateVersion != eventsource.AggregateVersion(i+1) {
+			t.Errorf("Project()[%d] = position %d version %d, want %d", i, e.Position, e.AggregateVersion, i+1)
+		}
+	}
+
+	async := &totals{}
+	subscriber := eventsource.NewSubscriber(store, NewCheckpointStore(db), db.Begin)
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	if err := subscriber.RunProjection(cctx, async, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunProjection() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if len(async.handled) != 2 {
+		t.Errorf("RunProjection() projected %d events, want 2", len(async.handled))
+	}
+}
+
+func TestEventStore_Outbox(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db, WithOutbox(func(_ context.Context, e eventsource.Event) ([]eventsource.OutboxMessage, error) {
+		m, err := eventsource.NewOutboxMessage("counters", e)
+		if err != nil {
+			return nil, err
+		}
+
+		return []eventsource.OutboxMessage{m}, nil
+	}))
+
+	rolledBack, _ := db.Begin(ctx)
+
+	c := newCounter("c1")
+	c.Increment(ctx, 1)
+
+	if err := store.Save(ctx, rolledBack, c); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	_ = rolledBack.Rollback()
+
+	if messages := db.OutboxMessages(); len(messages) != 0 {
+		t.Fatalf("OutboxMessages() after Rollback() = %d messages, want 0", len(messages))
+	}
+
+	saveIncrements(t, db, store, "c1", 2)
+
+	messages := db.OutboxMessages()
+	if len(messages) != 2 {
+		t.Fatalf("OutboxMessages() = %d messages, want 2", len(messages))
+	}
+
+	if messages[0].Topic != "counters" || messages[0].Key != "c1" || messages[0].EventType != incrementedType || string(messages[0].Payload) != `{"by":1}` {
+		t.Errorf("OutboxMessages()[0] = %+v", messages[0])
+	}
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+// PruneSnapshots deletes the snapshots of every aggregate that the policy does
+// not keep and returns their number. Aggregates are processed in the order of
+// their ids, a chunk of them per transaction, like the other stores.
+func PruneSnapshots(ctx context.Context, db *DB, policy eventsource.RetentionPolicy, opts ...eventsource.PruneOption) (int64, error) {
+	options := eventsource.NewPruneOptions(opts...)
+
+	db.mu.RLock()
+	ids := make([]eventsource.AggregateID, 0, len(db.snapshots))
+	for id := range db.snapshots {
+		ids = append(ids, id)
+	}
+	db.mu.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	var total int64
+
+	for len(ids) > 0 {
+		size := options.ChunkSize
+		if size > len(ids) {
+			size = len(ids)
+		}
+
+		deleted, err := pruneSnapshotsChunk(ctx, db, policy, ids[:size]...)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		ids = ids[size:]
+	}
+
+	return total, nil
+}
+
+// pruneSnapshotsChunk prunes the snapshots of a chunk of aggregates in its own
+// transaction.
+func pruneSnapshotsChunk(ctx context.Context, db *DB, policy eventsource.RetentionPolicy, ids ...eventsource.AggregateID) (int64, error) {
+	t, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx := t.(*Tx)
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	deleted, err := pruneSnapshots(tx, policy, ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, tx.Commit()
+}
+
+// pruneSnapshots deletes the snapshots of the aggregates that the policy does
+// not keep.
+func pruneSnapshots(tx *Tx, policy eventsource.RetentionPolicy, ids ...eventsource.AggregateID) (int64, error) {
+	now := time.Now()
+
+	prunable := make(map[snapshotKey]struct{})
+	for _, id := range ids {
+		ss, err := tx.aggregateSnapshots(id)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, s := range policy.Prunable(ss, now) {
+			prunable[keyOf(s)] = struct{}{}
+		}
+	}
+
+	if len(prunable) == 0 {
+		return 0, nil
+	}
+
+	return tx.deleteSnapshots(func(s eventsource.Snapshot) bool {
+		_, ok := prunable[keyOf(s)]
+
+		return ok
+	})
+}
@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+const trackerType eventsource.AggregateType = "tracker"
+
+// tracker mirrors the increments of a counter onto the "mirror" counter.
+type tracker struct {
+	*eventsource.BaseAggregate
+	Total int `es:"total"`
+}
+
+func newTracker(key string) *tracker {
+	return &tracker{BaseAggregate: eventsource.InitAggregate(eventsource.SagaID(trackerType, key), trackerType)}
+}
+
+func (s *tracker) HandleEvent(ctx context.Context, e eventsource.EventReadModel) ([]eventsource.Command, error) {
+	var data struct {
+		By int `json:"by"`
+	}
+
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return nil, err
+	}
+
+	if err := eventsource.Raise(ctx, s, &tracked{BaseEvent: eventsource.NewBaseEvent(s, nil), By: data.By}); err != nil {
+		return nil, err
+	}
+
+	return []eventsource.Command{&increment{ID: "mirror", By: data.By}}, nil
+}
+
+func (s *tracker) ParseEvents(_ context.Context, ee ...eventsource.EventReadModel) []eventsource.Event {
+	results := make([]eventsource.Event, 0, len(ee))
+	for _, e := range ee {
+		event := &tracked{BaseEvent: e.InitBaseEvent()}
+		if err := eventsource.UnmarshalES(e.Data, event); err != nil {
+			continue
+		}
+
+		results = append(results, event)
+	}
+
+	return results
+}
+
+type tracked struct {
+	*eventsource.BaseEvent
+	By int `es:"by"`
+}
+
+func (e *tracked) Type() eventsource.EventType {
+	return "tracked"
+}
+
+func (e *tracked) ApplyTo(_ context.Context, a eventsource.Aggregate) {
+	a.(*tracker).Total += e.By
+}
+
+func TestSagaManager(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	store := NewEventStore(db)
+
+	commands := eventsource.NewCommandRegistry()
+	eventsource.RegisterCommand(commands, incrementType,
+		func(id eventsource.AggregateID) *counter { return newCounter(id.String()) },
+		func(ctx context.Context, c *counter, cmd *increment) error {
+			return c.Increment(ctx, cmd.By)
+		},
+	)
+
+	sagas := eventsource.NewSagaManager(store, eventsource.NewDispatcher(store, db.Begin, commands))
+	// The trackers are correlated by the id of the counter they track, and
+	// the increments of the mirror are not tracked.
+	eventsource.RegisterSaga(sagas, newTracker, func(e eventsource.EventReadModel) (string, bool) {
+		if e.AggregateID == "mirror" {
+			return "", false
+		}
+
+		return eventsource.CorrelateByAggregateID()(e)
+	}, incrementedType)
+
+	tx, _ := db.Begin(ctx)
+
+	for i, id := range []string{"c1", "c2", "c1"} {
+		c := newCounter(id)
+		if _, err := store.Load(ctx, tx, c); err != nil && !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		if err := c.Increment(ctx, i+1); err != nil {
+			t.Fatalf("Raise() error = %v", err)
+		}
+
+		if err := store.Save(ctx, tx, c); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	subscriber := eventsource.NewSubscriber(store, NewCheckpointStore(db), db.Begin)
+
+	// The increments of the mirror are not handled on the second run.
+	for i := 0; i < 2; i++ {
+		if err := subscriber.CatchUp(ctx, "sagas", 0, sagas); err != nil {
+			t.Fatalf("CatchUp() error = %v", err)
+		}
+	}
+
+	tx, _ = db.Begin(ctx)
+	defer tx.Rollback()
+
+	for id, want := range map[string]int{"c1": 4, "c2": 2} {
+		saga, err := store.Load(ctx, tx, newTracker(id))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		if s := saga.(*tracker); s.Total != want {
+			t.Errorf("Load() = saga of %s total %d, want %d", id, s.Total, want)
+		}
+
+		// The stream of the counter is not shared with its saga.
+		c, err := store.Load(ctx, tx, newCounter(id))
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		if c := c.(*counter); c.Value != want {
+			t.Errorf("Load() = %s value %d, want %d", id, c.Value, want)
+		}
+	}
+
+	mirror, err := store.Load(ctx, tx, newCounter("mirror"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if c := mirror.(*counter); c.Value != 6 || c.Version() != 3 {
+		t.Errorf("Load() = mirror value %d at version %d, want 6 at version 3", c.Value, c.Version())
+	}
+}
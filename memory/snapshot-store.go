@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+// NewSnapshotStore returns an eventsource.SnapshotStore keeping the snapshots
+// in db. It is the snapshot store of the event stores of db unless they are
+// given another one with WithSnapshotStore.
+func NewSnapshotStore(db *DB) eventsource.SnapshotStore {
+	return &snapshotStore{db: db}
+}
+
+type snapshotStore struct {
+	db *DB
+}
+
+func (s *snapshotStore) SaveSnapshot(_ context.Context, t eventsource.Transaction, snapshot eventsource.Snapshot) error {
+	tx, err := transaction(s.db, t)
+	if err != nil {
+		return err
+	}
+
+	snapshot.SchemaVersion = eventsource.NormalizeSnapshotVersion(snapshot.SchemaVersion)
+
+	return tx.insertSnapshots(snapshot)
+}
+
+func (s *snapshotStore) LatestSnapshot(_ context.Context, t eventsource.Transaction, id eventsource.AggregateID, schemaVersion int) (*eventsource.Snapshot, error) {
+	tx, err := transaction(s.db, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return latestSnapshot(tx, id, 0, schemaVersion)
+}
+
+func (s *snapshotStore) SnapshotAt(_ context.Context, t eventsource.Transaction, id eventsource.AggregateID, version eventsource.AggregateVersion, schemaVersion int) (*eventsource.Snapshot, error) {
+	tx, err := transaction(s.db, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return latestSnapshot(tx, id, version, schemaVersion)
+}
+
+func (s *snapshotStore) DeleteSnapshots(_ context.Context, t eventsource.Transaction, id eventsource.AggregateID, versions ...eventsource.AggregateVersion) error {
+	tx, err := transaction(s.db, t)
+	if err != nil {
+		return err
+	}
+
+	deleted := make(map[eventsource.AggregateVersion]struct{}, len(versions))
+	for _, v := range versions {
+		deleted[v] = struct{}{}
+	}
+
+	_, err = tx.deleteSnapshots(func(snapshot eventsource.Snapshot) bool {
+		if snapshot.AggregateID != id {
+			return false
+		}
+
+		_, ok := deleted[snapshot.AggregateVersion]
+
+		return len(versions) == 0 || ok
+	})
+
+	return err
+}
+
+// latestSnapshot returns the latest snapshot of the aggregate at or before the
+// version, or at any version when it is 0, taken with the schema version, or
+// with any schema version when it is 0.
+func latestSnapshot(tx *Tx, id eventsource.AggregateID, version eventsource.AggregateVersion, schemaVersion int) (*eventsource.Snapshot, error) {
+	ss, err := tx.aggregateSnapshots(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *eventsource.Snapshot
+	for i := range ss {
+		if schemaVersion != 0 && ss[i].SchemaVersion != schemaVersion {
+			continue
+		}
+
+		if version != 0 && ss[i].AggregateVersion > version {
+			continue
+		}
+
+		if latest == nil || ss[i].AggregateVersion > latest.AggregateVersion {
+			latest = &ss[i]
+		}
+	}
+
+	if latest == nil {
+		return nil, eventsource.ErrNoSnapshotFound
+	}
+
+	return latest, nil
+}
@@ -8,13 +8,15 @@ type Aggregate interface {
 	Version() AggregateVersion
 	Changes() []Event
 	StackChange(change Event)
+	// Deprecated: snapshots are taken on Save by the SnapshotStrategy.
 	StackSnapshot(snapshot *Snapshot)
+	// Deprecated: snapshots are taken on Save by the SnapshotStrategy.
 	StackedSnapshots() []*Snapshot
+	// Deprecated: snapshots are taken on Save by the SnapshotStrategy.
 	SnapshotsWithFrequency(frequency int) []*Snapshot
 	SetVersion(version AggregateVersion)
 	IncrementVersion()
 	PrepareForLoading()
-	ParseEvents(context.Context, ...EventReadModel) []Event
 }
 
 type BaseAggregate struct {
@@ -57,23 +59,33 @@ func (a *BaseAggregate) StackChange(change Event) {
 	a.changes = append(a.changes, change)
 }
 
+// StackSnapshot stacks a snapshot of the aggregate. Applying events no longer
+// stacks snapshots.
+//
+// Deprecated: snapshots are taken on Save by the SnapshotStrategy.
 func (a *BaseAggregate) StackSnapshot(snapshot *Snapshot) {
 	a.snapshots = append(a.snapshots, snapshot)
 }
 
+// StackedSnapshots returns the snapshots stacked with StackSnapshot.
+//
+// Deprecated: snapshots are taken on Save by the SnapshotStrategy.
 func (a *BaseAggregate) StackedSnapshots() []*Snapshot {
 	return a.snapshots
 }
 
+// SnapshotsWithFrequency returns the stacked snapshots of the versions
+// selected by EveryNEvents(frequency).
+//
+// Deprecated: snapshots are taken on Save by the SnapshotStrategy, use
+// WithSnapshotStrategy(EveryNEvents(frequency)).
 func (a *BaseAggregate) SnapshotsWithFrequency(frequency int) []*Snapshot {
 	results := make([]*Snapshot, 0)
 
-	if frequency == 0 {
-		return results
-	}
+	strategy := EveryNEvents(frequency)
 
 	for _, snapshot := range a.StackedSnapshots() {
-		if int(snapshot.AggregateVersion)%frequency == 0 {
+		if strategy.ShouldSnapshot(context.Background(), SnapshotContext{Version: snapshot.AggregateVersion}) {
 			results = append(results, snapshot)
 		}
 	}
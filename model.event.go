@@ -111,6 +111,7 @@ func (e *BaseEvent) SetVersion(version AggregateVersion) {
 }
 
 type EventReadModel struct {
+	Position         int64                  `json:"position"` // Position of the event in the global stream of the event store.
 	ID               EventID                `json:"id"`
 	Type             EventType              `json:"type"`
 	OccurredAt       time.Time              `json:"occurred_at"`
@@ -121,6 +122,36 @@ type EventReadModel struct {
 	Data             json.RawMessage        `json:"data"`
 }
 
+// NewEventReadModel serializes the event the way the event stores store it, so
+// that it reads as if it had been loaded from them. Its position is unknown.
+func NewEventReadModel(e Event) (EventReadModel, error) {
+	data, err := MarshalES(e)
+	if err != nil {
+		return EventReadModel{}, err
+	}
+
+	b, err := MarshalES(EventMetadata(e))
+	if err != nil {
+		return EventReadModel{}, err
+	}
+
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(b, &metadata); err != nil {
+		return EventReadModel{}, err
+	}
+
+	return EventReadModel{
+		ID:               e.ID(),
+		Type:             e.Type(),
+		OccurredAt:       e.OccurredAt(),
+		AggregateID:      e.AggregateID(),
+		AggregateType:    e.AggregateType(),
+		AggregateVersion: e.AggregateVersion(),
+		Metadata:         metadata,
+		Data:             data,
+	}, nil
+}
+
 func (r *EventReadModel) InitBaseEvent() *BaseEvent {
 	return initBaseEvent(
 		r.ID,
@@ -0,0 +1,163 @@
+package eventsource
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxMessage is a message stored in the outbox in the transaction of the
+// events it derives from, and published afterwards by a relay.
+type OutboxMessage struct {
+	ID            MessageID
+	Topic         string
+	Key           string
+	EventID       EventID
+	EventType     EventType
+	AggregateID   AggregateID
+	AggregateType AggregateType
+	Payload       []byte
+	Metadata      Metadata
+	CreatedAt     time.Time
+	Attempts      int
+}
+
+// NewOutboxMessage returns a message to publish on the topic carrying the
+// serialized event, keyed by the id of its aggregate.
+func NewOutboxMessage(topic string, e Event) (OutboxMessage, error) {
+	payload, err := MarshalES(e)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+
+	return OutboxMessage{
+		ID:            NewMessageID(),
+		Topic:         topic,
+		Key:           e.AggregateID().String(),
+		EventID:       e.ID(),
+		EventType:     e.Type(),
+		AggregateID:   e.AggregateID(),
+		AggregateType: e.AggregateType(),
+		Payload:       payload,
+		Metadata:      EventMetadata(e),
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+// OutboxMapper derives the messages to store in the outbox from an event
+// being saved. It may return no message at all.
+type OutboxMapper func(ctx context.Context, e Event) ([]OutboxMessage, error)
+
+// MapOutboxMessages maps the events to their outbox messages, in order.
+func MapOutboxMessages(ctx context.Context, mapper OutboxMapper, ee ...Event) ([]OutboxMessage, error) {
+	results := make([]OutboxMessage, 0)
+	if mapper == nil {
+		return results, nil
+	}
+
+	for _, e := range ee {
+		messages, err := mapper(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, messages...)
+	}
+
+	return results, nil
+}
+
+// Publisher publishes the messages relayed from the outbox, e.g. to a
+// message broker. Messages are delivered at least once.
+type Publisher interface {
+	Publish(ctx context.Context, m OutboxMessage) error
+}
+
+type PublisherFunc func(ctx context.Context, m OutboxMessage) error
+
+func (f PublisherFunc) Publish(ctx context.Context, m OutboxMessage) error {
+	return f(ctx, m)
+}
+
+// Backoff returns how long to wait before the next attempt given the number of
+// attempts already made.
+type Backoff func(attempts int) time.Duration
+
+// ExponentialBackoff doubles the delay after each attempt, starting from base
+// and never exceeding max.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempts int) time.Duration {
+		delay := base
+		for i := 1; i < attempts && delay < max; i++ {
+			delay *= 2
+		}
+
+		if delay > max {
+			return max
+		}
+
+		return delay
+	}
+}
+
+type RelayOption func(*RelayOptions)
+
+// WithRelayBatchSize sets the maximum number of messages claimed at once.
+func WithRelayBatchSize(size int) RelayOption {
+	return func(opt *RelayOptions) {
+		if size > 0 {
+			opt.BatchSize = size
+		}
+	}
+}
+
+// WithRelayPollInterval sets how long the relay waits for new messages once
+// the outbox is drained.
+func WithRelayPollInterval(interval time.Duration) RelayOption {
+	return func(opt *RelayOptions) {
+		opt.PollInterval = interval
+	}
+}
+
+// WithMaxAttempts sets the number of attempts after which a message is no
+// longer relayed. Zero means no limit.
+func WithMaxAttempts(attempts int) RelayOption {
+	return func(opt *RelayOptions) {
+		opt.MaxAttempts = attempts
+	}
+}
+
+// WithRelayBackoff sets the delay before retrying a message that could not be
+// published.
+func WithRelayBackoff(backoff Backoff) RelayOption {
+	return func(opt *RelayOptions) {
+		opt.Backoff = backoff
+	}
+}
+
+func NewRelayOptions(opts ...RelayOption) *RelayOptions {
+	const (
+		defaultBatchSize    = 100
+		defaultPollInterval = time.Second
+		defaultMaxAttempts  = 10
+	)
+
+	result := &RelayOptions{
+		BatchSize:    defaultBatchSize,
+		PollInterval: defaultPollInterval,
+		MaxAttempts:  defaultMaxAttempts,
+		Backoff:      ExponentialBackoff(time.Second, 5*time.Minute),
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+type RelayOptions struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	Backoff      Backoff
+}
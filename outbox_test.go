@@ -0,0 +1,29 @@
+package eventsource
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 10*time.Second)
+
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{name: "first attempt", attempts: 1, want: time.Second},
+		{name: "second attempt", attempts: 2, want: 2 * time.Second},
+		{name: "fourth attempt", attempts: 4, want: 8 * time.Second},
+		{name: "capped", attempts: 5, want: 10 * time.Second},
+		{name: "far beyond the cap", attempts: 100, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoff(tt.attempts); got != tt.want {
+				t.Errorf("ExponentialBackoff()(%d) = %s, want %s", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewCheckpointStore returns an eventsource.CheckpointStore persisting the
+// checkpoints of the subscriptions in the checkpoints table of the schema.
+func NewCheckpointStore(tracer trace.Tracer, options *Options) (eventsource.CheckpointStore, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkpointStore{
+		options: options,
+		tracer:  tracer,
+	}, nil
+}
+
+type checkpointStore struct {
+	options *Options
+	tracer  trace.Tracer
+}
+
+// LoadCheckpoint locks the checkpoint until the end of the transaction so that
+// concurrent instances of a subscription handle each batch only once. The lock
+// is an advisory lock keyed on the name of the subscription, so that it also
+// holds before the first checkpoint is saved.
+func (s *checkpointStore) LoadCheckpoint(ctx context.Context, t eventsource.Transaction, name string) (int64, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.checkpointStore.LoadCheckpoint")
+	defer span.End()
+
+	tx, err := connFor(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	if _, err := tx.exec(ctx, "select pg_advisory_xact_lock(hashtext($1), hashtext($2)); ", s.checkpointsTableName(), name); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString("select position ")
+	b.WriteString(fmt.Sprintf("from %s ", s.checkpointsTableName()))
+	b.WriteString("where name = $1; ")
+
+	var position int64
+	if err := tx.queryRow(ctx, b.String(), name).Scan(&position); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, eventsource.ErrNoCheckpointFound
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	return position, nil
+}
+
+func (s *checkpointStore) SaveCheckpoint(ctx context.Context, t eventsource.Transaction, name string, position int64) error {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.checkpointStore.SaveCheckpoint")
+	defer span.End()
+
+	tx, err := connFor(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("insert into %s (name, position, updated_at) ", s.checkpointsTableName()))
+	b.WriteString("values ($1, $2, $3) ")
+	b.WriteString("on conflict (name) do update ")
+	b.WriteString("set position = excluded.position, updated_at = excluded.updated_at; ")
+
+	if _, err := tx.exec(ctx, b.String(), name, position, time.Now().UTC()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *checkpointStore) checkpointsTableName() string {
+	return s.options.computeTableName(s.options.checkpointStorageParams.tableName)
+}
@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"github.com/thefabric-io/eventsource"
+)
+
+// errVersionConflict is returned by conn.insertEvents when an event conflicts
+// with a stored version of its aggregate.
+var errVersionConflict = errors.New("version conflict")
+
+// conn runs the queries of the stores on the transaction of a driver.
+type conn interface {
+	exec(ctx context.Context, query string, args ...any) (int64, error)
+	query(ctx context.Context, query string, args ...any) (rows, error)
+	queryRow(ctx context.Context, query string, args ...any) row
+	// execBatch runs the statements in order, in a single round trip when
+	// the driver supports it.
+	execBatch(ctx context.Context, statements ...statement) error
+	// insertEvents inserts the events in the table and returns their
+	// positions by id.
+	insertEvents(ctx context.Context, table string, events []*Event) (map[string]int64, error)
+	// array returns the driver representation of a text array parameter.
+	array(values []string) any
+}
+
+type rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close()
+}
+
+type row interface {
+	Scan(dest ...any) error
+}
+
+type statement struct {
+	query string
+	args  []any
+}
+
+// connFor returns the conn running queries on the transaction: pgx
+// transactions are used natively, other transactions must implement DBTX.
+func connFor(t eventsource.Transaction) (conn, error) {
+	if tx, ok := eventsource.TransactionAs[pgxTransaction](t); ok {
+		return &pgxConn{tx: tx.PgxTx()}, nil
+	}
+
+	tx, err := dbtx(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlConn{tx: tx}, nil
+}
+
+type sqlConn struct {
+	tx DBTX
+}
+
+func (c *sqlConn) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	result, err := c.tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (c *sqlConn) query(ctx context.Context, query string, args ...any) (rows, error) {
+	r, err := c.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqlRows{Rows: r}, nil
+}
+
+func (c *sqlConn) queryRow(ctx context.Context, query string, args ...any) row {
+	return c.tx.QueryRowContext(ctx, query, args...)
+}
+
+func (c *sqlConn) execBatch(ctx context.Context, statements ...statement) error {
+	for _, s := range statements {
+		if _, err := c.tx.ExecContext(ctx, s.query, s.args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertEvents inserts the events within a savepoint, so that a conflicting
+// version leaves the transaction usable to report the actual version and none
+// of the events inserted.
+func (c *sqlConn) insertEvents(ctx context.Context, table string, events []*Event) (map[string]int64, error) {
+	insertBuilder := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).
+		Insert(table).
+		Columns(eventInsertColumns...).
+		Suffix("returning id, position")
+
+	for _, e := range events {
+		insertBuilder = insertBuilder.Values(e.insertValues()...)
+	}
+
+	query, args, err := insertBuilder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.tx.ExecContext(ctx, "savepoint insert_events; "); err != nil {
+		return nil, err
+	}
+
+	positions, err := scanPositions(c.query(ctx, query, args...))
+	if err != nil {
+		if _, rollbackErr := c.tx.ExecContext(ctx, "rollback to savepoint insert_events; "); rollbackErr != nil {
+			return nil, rollbackErr
+		}
+
+		if isUniqueViolation(err) {
+			return nil, errVersionConflict
+		}
+
+		return nil, err
+	}
+
+	if _, err := c.tx.ExecContext(ctx, "release savepoint insert_events; "); err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+func (c *sqlConn) array(values []string) any {
+	return pq.Array(values)
+}
+
+// isUniqueViolation reports whether err is a unique violation reported by
+// lib/pq or by pgx through its database/sql driver.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == uniqueViolation
+	}
+
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+type sqlRows struct {
+	*sql.Rows
+}
+
+func (r sqlRows) Close() {
+	_ = r.Rows.Close()
+}
+
+var eventInsertColumns = []string{
+	"id",
+	"type",
+	"occurred_at",
+	"registered_at",
+	"aggregate_id",
+	"aggregate_type",
+	"aggregate_version",
+	"data",
+	"metadata",
+}
+
+func (e *Event) insertValues() []any {
+	return []any{
+		e.ID,
+		e.Type,
+		e.OccurredAt,
+		e.RegisteredAt,
+		e.AggregateID,
+		e.AggregateType,
+		e.AggregateVersion,
+		e.Data,
+		e.Metadata,
+	}
+}
+
+// scanPositions reads the id and the position of events.
+func scanPositions(r rows, err error) (map[string]int64, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	positions := make(map[string]int64)
+	for r.Next() {
+		var (
+			id       string
+			position int64
+		)
+
+		if err := r.Scan(&id, &position); err != nil {
+			return nil, err
+		}
+
+		positions[id] = position
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+func insertStatement(b squirrel.InsertBuilder) (statement, error) {
+	query, args, err := b.ToSql()
+	if err != nil {
+		return statement{}, fmt.Errorf("could not build insert statement: %w", err)
+	}
+
+	return statement{query: query, args: args}, nil
+}
@@ -5,77 +5,196 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Masterminds/squirrel"
-	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
 	"github.com/thefabric-io/eventsource"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
 func NewEventStore(tracer trace.Tracer, options *Options) (eventsource.EventStore, error) {
-	if options == nil || options.IsZero() {
-		options = DefaultOptions()
+	options, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(strings.TrimSpace(options.schemaName)) == 0 {
-		options.schemaName = "es"
+	s := &eventStore{
+		options: options,
+		tracer:  tracer,
+		table: &snapshotStore{
+			options: options,
+			tracer:  tracer,
+		},
 	}
 
-	if err := options.Validate(); err != nil {
-		return nil, err
+	s.snapshots = s.table
+	if options.snapshotStore != nil {
+		s.snapshots, s.table = options.snapshotStore, nil
 	}
 
-	return &eventStore{
-		options: options,
-		tracer:  tracer,
-	}, nil
+	return s, nil
 }
 
 type eventStore struct {
-	options *Options
-	tracer  trace.Tracer
+	options   *Options
+	tracer    trace.Tracer
+	snapshots eventsource.SnapshotStore
+	// table is the snapshot store of the snapshots table, nil when the
+	// snapshots are kept in another snapshot store.
+	table *snapshotStore
+}
+
+// Registry returns the registry the events are decoded with, nil when the
+// aggregates parse them.
+func (s *eventStore) Registry() *eventsource.Registry {
+	return s.options.registry
 }
 
 func (s *eventStore) Save(ctx context.Context, t eventsource.Transaction, a eventsource.Aggregate, opts ...eventsource.SaveOption) error {
 	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.Save")
 	defer span.End()
 
-	if t == nil {
-		return eventsource.ErrTransactionIsRequired
-	}
+	ctx = eventsource.ContextWithLogger(ctx, s.options.logger)
+
+	tx, err := connFor(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-	tx := t.(*sqlx.Tx)
+		return err
+	}
 
 	options := eventsource.NewSaveOptions(opts...)
 
-	if err := s.save(ctx, tx, a.Changes()); err != nil {
+	if options.Expectation == eventsource.ExpectExactVersion {
+		current, err := s.currentVersion(ctx, tx, a.ID())
+		if err != nil {
+			span.RecordError(err)
+
+			return err
+		}
+
+		if err := options.CheckVersion(a, current); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return err
+		}
+	}
+
+	events, err := s.save(ctx, tx, a.Changes())
+	if err != nil {
 		span.RecordError(err)
 
 		return err
 	}
 
+	if err := eventsource.Project(ctx, t, events, s.options.projections...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	messages, err := eventsource.MapOutboxMessages(ctx, s.options.outboxMapper, a.Changes()...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	statements := make([]statement, 0, 3)
+
+	if len(messages) > 0 {
+		stmt, err := s.outboxStatement(messages...)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return err
+		}
+
+		statements = append(statements, stmt)
+	}
+
 	if options.WithSnapshot {
-		snapshots := a.SnapshotsWithFrequency(options.WithSnapshotFrequency)
-		if len(snapshots) > 0 {
-			if err := s.saveSnapshots(ctx, tx, snapshots...); err != nil {
+		strategy := options.Strategy(a)
+
+		var last *eventsource.Snapshot
+		if eventsource.ReadsLastSnapshot(strategy) {
+			last, err = s.latestSnapshotInfo(ctx, t, tx, a.ID())
+			if err != nil && !eventsource.ErrIsSnapshotNotFound(err) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return err
+			}
+		}
+
+		snapshot, err := eventsource.TakeSnapshot(ctx, strategy, a, last)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return err
+		}
+
+		switch {
+		case snapshot == nil:
+		case s.table != nil:
+			stmt, err := s.table.insertStatement(snapshot)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return err
+			}
+
+			statements = append(statements, stmt)
+
+			if s.options.retention != nil {
+				ids := tx.array([]string{a.ID().String()})
+				statements = append(statements, pruneStatement(s.table.tableName(), ids, *s.options.retention, time.Now().UTC()))
+			}
+		default:
+			if err := s.snapshots.SaveSnapshot(ctx, t, *snapshot); err != nil {
 				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
 
 				return err
 			}
 		}
 	}
 
+	if err := tx.execBatch(ctx, statements...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	eventsource.LoggerFromContext(ctx).Log(ctx, eventsource.LevelDebug, "events saved",
+		append(eventsource.AggregateFields(a), eventsource.Field{Key: "events", Value: len(events)})...)
+
 	return nil
 }
 
-func (s *eventStore) EventsHistory(ctx context.Context, tx eventsource.Transaction, aggregateID, aggregateType string, fromVersion int, limit int) ([]eventsource.EventReadModel, error) {
+func (s *eventStore) EventsHistory(ctx context.Context, t eventsource.Transaction, aggregateID, aggregateType string, fromVersion int, limit int) ([]eventsource.EventReadModel, error) {
 	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.EventsHistory")
 	defer span.End()
 
+	tx, err := connFor(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
 	ee, err := s.loadEvents(ctx, tx, eventsource.AggregateID(aggregateID), eventsource.AggregateType(aggregateType), eventsource.AggregateVersion(fromVersion), limit)
 	if err != nil {
 		span.RecordError(err)
@@ -91,25 +210,61 @@ func (s *eventStore) Load(ctx context.Context, t eventsource.Transaction, aggreg
 	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.Load")
 	defer span.End()
 
+	return s.load(ctx, t, aggregate, eventsource.LoadTarget{})
+}
+
+func (s *eventStore) LoadAt(ctx context.Context, t eventsource.Transaction, aggregate eventsource.Aggregate, target eventsource.LoadTarget) (eventsource.Aggregate, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.LoadAt")
+	defer span.End()
+
+	return s.load(ctx, t, aggregate, target)
+}
+
+// load loads the aggregate at the target, recording errors on the span of ctx.
+func (s *eventStore) load(ctx context.Context, t eventsource.Transaction, aggregate eventsource.Aggregate, target eventsource.LoadTarget) (eventsource.Aggregate, error) {
+	span := trace.SpanFromContext(ctx)
+
+	ctx = eventsource.ContextWithLogger(ctx, s.options.logger)
+
 	if aggregate.ID().IsZero() || aggregate.Type().IsZero() {
 		return nil, errors.New("aggragate id and type must be specified")
 	}
 
 	aggregate.PrepareForLoading()
 
-	if t == nil {
-		err := eventsource.ErrTransactionIsRequired
-
+	tx, err := connFor(t)
+	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 
 		return nil, err
 	}
 
-	tx := t.(*sqlx.Tx)
+	// bound is the last version to replay, 0 when loading the current
+	// version.
+	bound := eventsource.AggregateVersion(0)
+
+	if target != (eventsource.LoadTarget{}) {
+		bound, err = s.boundOf(ctx, tx, aggregate, target)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		if bound == 0 {
+			err := eventsource.ErrAggregateDoNotExist
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+	}
 
-	latestSnapshot, err := s.loadLatestSnapshot(ctx, tx, aggregate.ID())
-	if err != nil && !eventsource.ErrIsSnapshotNotFound(err) {
+	snapshot, err := s.loadSnapshot(ctx, t, tx, aggregate, bound)
+	if err != nil {
 		span.RecordError(err)
 
 		return nil, err
@@ -118,100 +273,622 @@ func (s *eventStore) Load(ctx context.Context, t eventsource.Transaction, aggreg
 	snapshotExist := false
 	fromVersion := eventsource.AggregateVersion(1)
 
-	if !eventsource.ErrIsSnapshotNotFound(err) {
-		fromVersion = latestSnapshot.AggregateVersion.Next()
+	if snapshot != nil {
+		fromVersion = snapshot.AggregateVersion.Next()
 		snapshotExist = true
 	}
 
-	ee, err := s.loadEvents(ctx, tx, aggregate.ID(), aggregate.Type(), fromVersion, 0)
+	var ee []eventsource.EventReadModel
+
+	if bound == 0 || fromVersion <= bound {
+		limit := 0
+		if bound != 0 {
+			limit = int(bound - fromVersion + 1)
+		}
+
+		ee, err = s.loadEvents(ctx, tx, aggregate.ID(), aggregate.Type(), fromVersion, limit)
+		if err != nil {
+			span.RecordError(err)
+
+			return nil, err
+		}
+	}
+
+	if len(ee) == 0 && !snapshotExist {
+		err := eventsource.ErrAggregateDoNotExist
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	events, err := eventsource.ParseEvents(ctx, s.options.registry, aggregate, ee...)
 	if err != nil {
 		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
 		return nil, err
 	}
 
-	if len(ee) == 0 && !snapshotExist {
-		err := eventsource.ErrAggregateDoNotExist
+	aggregate, err = eventsource.Replay(ctx, aggregate, snapshot, events...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return aggregate, nil
+}
+
+func (s *eventStore) LoadMany(ctx context.Context, t eventsource.Transaction, aggregates ...eventsource.Aggregate) ([]eventsource.LoadResult, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.LoadMany")
+	defer span.End()
 
+	ctx = eventsource.ContextWithLogger(ctx, s.options.logger)
+
+	tx, err := connFor(t)
+	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 
 		return nil, err
 	}
 
-	events := aggregate.ParseEvents(ctx, ee...)
+	results := make([]eventsource.LoadResult, len(aggregates))
+	requested := make([]eventsource.Aggregate, 0, len(aggregates))
+
+	for i, a := range aggregates {
+		if a.ID().IsZero() || a.Type().IsZero() {
+			results[i].Err = errors.New("aggragate id and type must be specified")
+
+			continue
+		}
+
+		a.PrepareForLoading()
+		requested = append(requested, a)
+	}
 
-	return eventsource.Replay(ctx, aggregate, latestSnapshot, events...)
+	snapshots, err := s.loadSnapshots(ctx, t, tx, requested)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	fromVersions := make(map[aggregateKey]eventsource.AggregateVersion, len(requested))
+	for i, a := range requested {
+		from := eventsource.AggregateVersion(1)
+		if snapshots[i] != nil {
+			from = snapshots[i].AggregateVersion.Next()
+		}
+
+		key := aggregateKey{a.ID(), a.Type()}
+		if v, ok := fromVersions[key]; !ok || from < v {
+			fromVersions[key] = from
+		}
+	}
+
+	events, err := s.loadManyEvents(ctx, tx, fromVersions)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	j := 0
+	for i, a := range aggregates {
+		if results[i].Err != nil {
+			continue
+		}
+
+		results[i].Aggregate, results[i].Err = replayFrom(ctx, s.options.registry, a, snapshots[j], events[aggregateKey{a.ID(), a.Type()}])
+		j++
+	}
+
+	return results, nil
 }
 
-func (s *eventStore) save(ctx context.Context, tx *sqlx.Tx, events []eventsource.Event) error {
-	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.save")
+// loadSnapshots returns the latest snapshot of each aggregate compatible with
+// its schema version, or nil, in order.
+func (s *eventStore) loadSnapshots(ctx context.Context, t eventsource.Transaction, tx conn, aggregates []eventsource.Aggregate) ([]*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.loadSnapshots")
 	defer span.End()
 
-	if len(events) == 0 {
-		return eventsource.ErrNoEventsToStore
+	if s.table == nil {
+		snapshots, err := s.loadExternalSnapshots(ctx, t, tx, aggregates)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		return snapshots, nil
 	}
 
-	insertBuilder := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).
-		Insert(s.eventsTableName()).
-		Columns(
-			"id",
-			"type",
-			"occurred_at",
-			"registered_at",
-			"aggregate_id",
-			"aggregate_type",
-			"aggregate_version",
-			"data",
-			"metadata",
+	snapshots := make([]*eventsource.Snapshot, len(aggregates))
+
+	ids := make([]string, 0, len(aggregates))
+	for _, a := range aggregates {
+		ids = append(ids, a.ID().String())
+	}
+
+	latest, err := s.table.loadLatest(ctx, tx, ids)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	for i, a := range aggregates {
+		schemaVersion := eventsource.SnapshotVersionOf(a)
+
+		for _, snapshot := range latest[aggregateKey{a.ID(), a.Type()}] {
+			if snapshot.SchemaVersion == schemaVersion {
+				snapshots[i] = snapshot
+			}
+		}
+	}
+
+	return snapshots, nil
+}
+
+// loadExternalSnapshots returns the latest snapshot of each aggregate kept in
+// the snapshot store compatible with its schema version, or nil, in order.
+// SnapshotStore has no batch method, so the snapshots are fetched one
+// aggregate at a time; the events they were taken after are then checked with
+// a single query. The snapshots without an event id or whose event is not
+// stored at their version fall back to the search of externalSnapshot.
+func (s *eventStore) loadExternalSnapshots(ctx context.Context, t eventsource.Transaction, tx conn, aggregates []eventsource.Aggregate) ([]*eventsource.Snapshot, error) {
+	snapshots := make([]*eventsource.Snapshot, len(aggregates))
+	eventIDs := make([]string, 0, len(aggregates))
+
+	for i, a := range aggregates {
+		snapshot, err := s.snapshots.LatestSnapshot(ctx, t, a.ID(), eventsource.SnapshotVersionOf(a))
+		if eventsource.ErrIsSnapshotNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots[i] = snapshot
+		if !snapshot.EventID.IsZero() {
+			eventIDs = append(eventIDs, snapshot.EventID.String())
+		}
+	}
+
+	stored, err := s.storedEvents(ctx, tx, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, a := range aggregates {
+		snapshot := snapshots[i]
+		if snapshot == nil || stored[snapshot.EventID] == (storedEvent{key: aggregateKey{a.ID(), a.Type()}, version: snapshot.AggregateVersion}) {
+			continue
+		}
+
+		if snapshots[i], err = s.loadSnapshot(ctx, t, tx, a, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return snapshots, nil
+}
+
+// storedEvent is the stream and version an event is stored at.
+type storedEvent struct {
+	key     aggregateKey
+	version eventsource.AggregateVersion
+}
+
+// storedEvents returns the stream and version of the stored events among the
+// ids.
+func (s *eventStore) storedEvents(ctx context.Context, tx conn, ids []string) (map[eventsource.EventID]storedEvent, error) {
+	results := make(map[eventsource.EventID]storedEvent, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString("select id, aggregate_id, aggregate_type, aggregate_version ")
+	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
+	b.WriteString("where id = any($1); ")
+
+	rows, err := tx.query(ctx, b.String(), tx.array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id, aggregateID, aggregateType string
+			version                        int64
 		)
 
+		if err := rows.Scan(&id, &aggregateID, &aggregateType, &version); err != nil {
+			return nil, err
+		}
+
+		results[eventsource.EventID(id)] = storedEvent{
+			key:     aggregateKey{eventsource.AggregateID(aggregateID), eventsource.AggregateType(aggregateType)},
+			version: eventsource.AggregateVersion(version),
+		}
+	}
+
+	return results, rows.Err()
+}
+
+// aggregateKey identifies the stream of an aggregate: aggregates of distinct
+// types may share an id.
+type aggregateKey struct {
+	id eventsource.AggregateID
+	t  eventsource.AggregateType
+}
+
+// loadManyEvents returns the events of each aggregate from its version, by
+// aggregate and ordered by version.
+func (s *eventStore) loadManyEvents(ctx context.Context, tx conn, fromVersions map[aggregateKey]eventsource.AggregateVersion) (map[aggregateKey][]eventsource.EventReadModel, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.loadManyEvents")
+	defer span.End()
+
+	results := make(map[aggregateKey][]eventsource.EventReadModel, len(fromVersions))
+	if len(fromVersions) == 0 {
+		return results, nil
+	}
+
+	ids := make([]string, 0, len(fromVersions))
+	types := make([]string, 0, len(fromVersions))
+	versions := make([]string, 0, len(fromVersions))
+
+	for key, v := range fromVersions {
+		ids = append(ids, key.id.String())
+		types = append(types, key.t.String())
+		versions = append(versions, strconv.FormatInt(v.Int64(), 10))
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("select %s ", eventColumns))
+	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
+	b.WriteString("join unnest($1::text[], $2::text[], $3::text[]::bigint[]) as requested(requested_id, requested_type, from_version) ")
+	b.WriteString("on aggregate_id = requested_id ")
+	b.WriteString("and aggregate_type = requested_type ")
+	b.WriteString("and aggregate_version >= from_version ")
+	b.WriteString("order by aggregate_id, aggregate_type, aggregate_version; ")
+
+	events, err := s.queryEvents(ctx, tx, b.String(), tx.array(ids), tx.array(types), tx.array(versions))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	for _, e := range events {
+		key := aggregateKey{e.AggregateID, e.AggregateType}
+		results[key] = append(results[key], e)
+	}
+
+	return results, nil
+}
+
+// replayFrom replays the events of the aggregate following the snapshot on top
+// of it, ignoring the events of other aggregate types.
+func replayFrom(ctx context.Context, registry *eventsource.Registry, a eventsource.Aggregate, snapshot *eventsource.Snapshot, ee []eventsource.EventReadModel) (eventsource.Aggregate, error) {
+	fromVersion := eventsource.AggregateVersion(1)
+	if snapshot != nil {
+		fromVersion = snapshot.AggregateVersion.Next()
+	}
+
+	events := make([]eventsource.EventReadModel, 0, len(ee))
+	for _, e := range ee {
+		if e.AggregateVersion >= fromVersion && e.AggregateType == a.Type() {
+			events = append(events, e)
+		}
+	}
+
+	if len(events) == 0 && snapshot == nil {
+		return nil, eventsource.ErrAggregateDoNotExist
+	}
+
+	parsed, err := eventsource.ParseEvents(ctx, registry, a, events...)
+	if err != nil {
+		return nil, err
+	}
+
+	return eventsource.Replay(ctx, a, snapshot, parsed...)
+}
+
+// boundOf returns the last version of the aggregate within the target.
+func (s *eventStore) boundOf(ctx context.Context, tx conn, a eventsource.Aggregate, target eventsource.LoadTarget) (eventsource.AggregateVersion, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.boundOf")
+	defer span.End()
+
+	b := strings.Builder{}
+
+	b.WriteString("select coalesce(max(aggregate_version), 0), ")
+	b.WriteString("coalesce(max(case when occurred_at <= $3 then aggregate_version end), 0) ")
+	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
+	b.WriteString("where aggregate_id = $1 ")
+	b.WriteString("and aggregate_type = $2; ")
+
+	var current, occurred eventsource.AggregateVersion
+	if err := tx.queryRow(ctx, b.String(), a.ID().String(), a.Type().String(), target.Time).Scan(&current, &occurred); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	return target.Bound(current, occurred), nil
+}
+
+// PurgeSnapshots deletes the snapshots of the aggregate type taken with
+// another schema version than schemaVersion. It only applies to the snapshots
+// kept in the snapshots table.
+func (s *eventStore) PurgeSnapshots(ctx context.Context, t eventsource.Transaction, aggregateType eventsource.AggregateType, schemaVersion int) (int64, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.PurgeSnapshots")
+	defer span.End()
+
+	if s.table == nil {
+		err := fmt.Errorf("%w: snapshots are kept in %T", eventsource.ErrSnapshotPurgeNotSupported, s.snapshots)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	tx, err := connFor(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	deleted, err := s.table.purge(ctx, tx, aggregateType, schemaVersion)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// loadSnapshot returns the latest snapshot of the aggregate at or before the
+// bound, or at any version when it is 0, compatible with its schema version, or
+// nil. Snapshots of another snapshot store left by rolled back transactions
+// are skipped.
+func (s *eventStore) loadSnapshot(ctx context.Context, t eventsource.Transaction, tx conn, a eventsource.Aggregate, bound eventsource.AggregateVersion) (*eventsource.Snapshot, error) {
+	var (
+		snapshot *eventsource.Snapshot
+		err      error
+	)
+
+	if s.table != nil {
+		snapshot, err = s.table.load(ctx, tx, a.ID(), bound, eventsource.SnapshotVersionOf(a))
+	} else {
+		snapshot, err = s.externalSnapshot(ctx, t, tx, a, bound)
+	}
+
+	if eventsource.ErrIsSnapshotNotFound(err) {
+		return nil, nil
+	}
+
+	return snapshot, err
+}
+
+// externalSnapshot returns the latest snapshot of the snapshot store at or
+// before the bound, or at the current version when it is 0. The store does
+// not take part in the transaction: the snapshots whose event is not the one
+// stored at their version were left by rolled back saves and are skipped.
+func (s *eventStore) externalSnapshot(ctx context.Context, t eventsource.Transaction, tx conn, a eventsource.Aggregate, bound eventsource.AggregateVersion) (*eventsource.Snapshot, error) {
+	if bound == 0 {
+		current, err := s.currentVersion(ctx, tx, a.ID())
+		if err != nil {
+			return nil, err
+		}
+
+		bound = current
+	}
+
+	for bound > 0 {
+		snapshot, err := s.snapshots.SnapshotAt(ctx, t, a.ID(), bound, eventsource.SnapshotVersionOf(a))
+		if err != nil {
+			return nil, err
+		}
+
+		if snapshot.EventID.IsZero() {
+			return snapshot, nil
+		}
+
+		eventID, err := s.eventIDAt(ctx, tx, a.ID(), snapshot.AggregateVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		if eventID == snapshot.EventID {
+			return snapshot, nil
+		}
+
+		bound = snapshot.AggregateVersion - 1
+	}
+
+	return nil, eventsource.ErrNoSnapshotFound
+}
+
+// eventIDAt returns the id of the event stored at the version of the
+// aggregate, or an empty id.
+func (s *eventStore) eventIDAt(ctx context.Context, tx conn, id eventsource.AggregateID, version eventsource.AggregateVersion) (eventsource.EventID, error) {
+	query := fmt.Sprintf("select id from %s where aggregate_id = $1 and aggregate_version = $2; ", s.eventsTableName())
+
+	var eventID string
+	if err := tx.queryRow(ctx, query, id.String(), version.Int64()).Scan(&eventID); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	return eventsource.EventID(eventID), nil
+}
+
+// latestSnapshotInfo returns the latest snapshot of the aggregate, whatever
+// its schema version, without its data when it is kept in the snapshots table.
+func (s *eventStore) latestSnapshotInfo(ctx context.Context, t eventsource.Transaction, tx conn, id eventsource.AggregateID) (*eventsource.Snapshot, error) {
+	if s.table != nil {
+		return s.table.loadInfo(ctx, tx, id)
+	}
+
+	return s.snapshots.LatestSnapshot(ctx, t, id, 0)
+}
+
+// save inserts the events and returns them as read models positioned in the
+// global stream.
+func (s *eventStore) save(ctx context.Context, tx conn, events []eventsource.Event) ([]eventsource.EventReadModel, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.save")
+	defer span.End()
+
+	if len(events) == 0 {
+		return nil, eventsource.ErrNoEventsToStore
+	}
+
+	sqlEvents := make([]*Event, 0, len(events))
 	for _, e := range events {
 		sqlEvent, err := FromEvent(e)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		insertBuilder = insertBuilder.Values(
-			sqlEvent.ID,
-			sqlEvent.Type,
-			sqlEvent.OccurredAt,
-			sqlEvent.RegisteredAt,
-			sqlEvent.AggregateID,
-			sqlEvent.AggregateType,
-			sqlEvent.AggregateVersion,
-			sqlEvent.Data,
-			sqlEvent.Metadata,
-		)
+		sqlEvents = append(sqlEvents, sqlEvent)
+	}
+
+	positions, err := tx.insertEvents(ctx, s.eventsTableName(), sqlEvents)
+	if errors.Is(err, errVersionConflict) {
+		err = s.conflictError(ctx, tx, events[0])
 	}
 
-	query, args, err := insertBuilder.ToSql()
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 
+		return nil, err
+	}
+
+	results := make([]eventsource.EventReadModel, 0, len(sqlEvents))
+	for _, sqlEvent := range sqlEvents {
+		sqlEvent.Position = sql.NullInt64{Int64: positions[sqlEvent.ID.String], Valid: true}
+
+		results = append(results, sqlEvent.ToReadModel())
+	}
+
+	return results, nil
+}
+
+func (s *eventStore) conflictError(ctx context.Context, tx conn, first eventsource.Event) error {
+	current, err := s.currentVersion(ctx, tx, first.AggregateID())
+	if err != nil {
 		return err
 	}
 
-	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+	return &eventsource.ConcurrencyConflictError{
+		AggregateID:     first.AggregateID(),
+		AggregateType:   first.AggregateType(),
+		ExpectedVersion: first.AggregateVersion() - 1,
+		ActualVersion:   current,
+	}
+}
+
+func (s *eventStore) currentVersion(ctx context.Context, tx conn, id eventsource.AggregateID) (eventsource.AggregateVersion, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.currentVersion")
+	defer span.End()
+
+	query := fmt.Sprintf("select coalesce(max(aggregate_version), 0) from %s where aggregate_id = $1; ", s.eventsTableName())
+
+	var version eventsource.AggregateVersion
+	if err := tx.queryRow(ctx, query, id.String()).Scan(&version); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 
-		return err
+		return 0, err
 	}
 
-	return nil
+	return version, nil
 }
 
-func (s *eventStore) loadEvents(ctx context.Context, t eventsource.Transaction, id eventsource.AggregateID, aggregateType eventsource.AggregateType, fromVersion eventsource.AggregateVersion, limit int) ([]eventsource.EventReadModel, error) {
-	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.loadEvents")
+func (s *eventStore) ReadAll(ctx context.Context, t eventsource.Transaction, fromPosition int64, opts ...eventsource.ReadOption) ([]eventsource.EventReadModel, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.ReadAll")
 	defer span.End()
 
-	tx := t.(*sqlx.Tx)
+	tx, err := connFor(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	options := eventsource.NewReadOptions(opts...)
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("select %s ", eventColumns))
+	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
+	// Positions are allocated on insert and may become visible out of order:
+	// only the events of the transactions older than every running one are
+	// read, ordered by transaction then position, after the event at the
+	// position the reader stopped at.
+	b.WriteString("where (transaction_id, position) > (")
+	b.WriteString(fmt.Sprintf("coalesce((select transaction_id from %s where position <= $1 order by position desc limit 1), '0'::xid8), ", s.eventsTableName()))
+	b.WriteString("$1) ")
+	b.WriteString("and transaction_id < pg_snapshot_xmin(pg_current_snapshot()) ")
+
+	args := []any{
+		fromPosition,
+	}
+
+	if len(options.AggregateTypes) > 0 {
+		args = append(args, tx.array(toStrings(options.AggregateTypes)))
+		b.WriteString(fmt.Sprintf("and aggregate_type = any($%d) ", len(args)))
+	}
+
+	if len(options.EventTypes) > 0 {
+		args = append(args, tx.array(toStrings(options.EventTypes)))
+		b.WriteString(fmt.Sprintf("and type = any($%d) ", len(args)))
+	}
+
+	args = append(args, options.BatchSize)
+	b.WriteString("order by transaction_id, position ")
+	b.WriteString(fmt.Sprintf("limit $%d; ", len(args)))
+
+	events, err := s.queryEvents(ctx, tx, b.String(), args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (s *eventStore) loadEvents(ctx context.Context, tx conn, id eventsource.AggregateID, aggregateType eventsource.AggregateType, fromVersion eventsource.AggregateVersion, limit int) ([]eventsource.EventReadModel, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.loadEvents")
+	defer span.End()
 
 	b := strings.Builder{}
 
-	b.WriteString("select id, type, occurred_at, aggregate_id, aggregate_type, aggregate_version, data, metadata, registered_at ")
+	b.WriteString(fmt.Sprintf("select %s ", eventColumns))
 	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
 	b.WriteString("where aggregate_id = $1 ")
 	b.WriteString("and aggregate_version >= $2 ")
@@ -229,21 +906,42 @@ func (s *eventStore) loadEvents(ctx context.Context, t eventsource.Transaction,
 		args = append(args, limit)
 	}
 
-	query := b.String()
-
-	rows, err := tx.QueryContext(ctx, query, args...)
+	events, err := s.queryEvents(ctx, tx, b.String(), args...)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 
 		return nil, err
 	}
+
+	return events, nil
+}
+
+func toStrings[T ~string](values []T) []string {
+	results := make([]string, 0, len(values))
+	for _, v := range values {
+		results = append(results, string(v))
+	}
+
+	return results
+}
+
+const eventColumns = "position, id, type, occurred_at, aggregate_id, aggregate_type, aggregate_version, data, metadata, registered_at"
+
+// queryEvents runs a query selecting the eventColumns and returns the events
+// upcasted to their latest schema version.
+func (s *eventStore) queryEvents(ctx context.Context, tx conn, query string, args ...any) ([]eventsource.EventReadModel, error) {
+	rows, err := tx.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	events := make([]eventsource.EventReadModel, 0)
 	for rows.Next() {
 		var event Event
 		if err := rows.Scan(
+			&event.Position,
 			&event.ID,
 			&event.Type,
 			&event.OccurredAt,
@@ -254,116 +952,68 @@ func (s *eventStore) loadEvents(ctx context.Context, t eventsource.Transaction,
 			&event.Metadata,
 			&event.RegisteredAt,
 		); err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-
 			return nil, err
 		}
 
 		events = append(events, event.ToReadModel())
 	}
 
-	return events, nil
-}
-
-func (s *eventStore) computeTableName(tableName string) string {
-	schema := s.options.schemaName
-	if schema == "" {
-		return tableName
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return fmt.Sprintf("%s.%s", schema, tableName)
+	return s.options.upcasters.UpcastAll(ctx, events...)
 }
 
-func (s *eventStore) saveSnapshots(ctx context.Context, tx eventsource.Transaction, ss ...*eventsource.Snapshot) error {
-	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.saveSnapshots")
-	defer span.End()
-
-	t := tx.(*sqlx.Tx)
+func (s *eventStore) computeTableName(tableName string) string {
+	return s.options.computeTableName(tableName)
+}
 
+func (s *eventStore) outboxStatement(mm ...eventsource.OutboxMessage) (statement, error) {
 	insertBuilder := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).
-		Insert(s.snapshotsTableName()).
+		Insert(s.outboxTableName()).
 		Columns(
+			"id",
+			"topic",
+			"key",
+			"event_id",
+			"event_type",
 			"aggregate_id",
 			"aggregate_type",
-			"aggregate_version",
-			"taken_at",
-			"registered_at",
-			"data",
+			"payload",
+			"metadata",
+			"created_at",
+			"attempts",
 		)
 
-	for _, snap := range ss {
-		sqlSnap := FromSnapshot(*snap)
-		insertBuilder = insertBuilder.Values(
-			sqlSnap.AggregateID,
-			sqlSnap.AggregateType,
-			sqlSnap.AggregateVersion,
-			sqlSnap.TakenAt,
-			time.Now().UTC(),
-			sqlSnap.Data,
-		)
-	}
-
-	query, args, err := insertBuilder.ToSql()
-	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-
-		return err
-	}
-
-	_, err = t.ExecContext(ctx, query, args...)
-	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-
-		return err
-	}
-
-	return nil
-}
-
-func (s *eventStore) loadLatestSnapshot(ctx context.Context, tx *sqlx.Tx, id eventsource.AggregateID) (*eventsource.Snapshot, error) {
-	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.eventStore.loadLatestSnapshot")
-	defer span.End()
-
-	b := strings.Builder{}
-
-	b.WriteString("select aggregate_id, aggregate_type, aggregate_version, taken_at, data ")
-	b.WriteString(fmt.Sprintf("from %s ", s.snapshotsTableName()))
-	b.WriteString("where aggregate_id = $1 ")
-	b.WriteString("order by aggregate_version desc ")
-	b.WriteString("limit 1; ")
-
-	query := b.String()
-
-	row := tx.QueryRowContext(ctx, query, id.String())
-
-	snapshot := Snapshot{}
-	if err := row.Scan(
-		&snapshot.AggregateID,
-		&snapshot.AggregateType,
-		&snapshot.AggregateVersion,
-		&snapshot.TakenAt,
-		&snapshot.Data,
-	); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-
-		if err == sql.ErrNoRows {
-			return nil, eventsource.ErrNoSnapshotFound
+	for _, m := range mm {
+		sqlMessage, err := FromOutboxMessage(m)
+		if err != nil {
+			return statement{}, err
 		}
 
-		return nil, err
+		insertBuilder = insertBuilder.Values(
+			sqlMessage.ID,
+			sqlMessage.Topic,
+			sqlMessage.Key,
+			sqlMessage.EventID,
+			sqlMessage.EventType,
+			sqlMessage.AggregateID,
+			sqlMessage.AggregateType,
+			sqlMessage.Payload,
+			sqlMessage.Metadata,
+			sqlMessage.CreatedAt,
+			sqlMessage.Attempts,
+		)
 	}
 
-	return snapshot.ToSnapshot(), nil
+	return insertStatement(insertBuilder)
 }
 
 func (s *eventStore) eventsTableName() string {
 	return s.computeTableName(s.options.eventStorageParams.tableName)
 }
 
-func (s *eventStore) snapshotsTableName() string {
-	return s.computeTableName(s.options.snapshotStorageParams.tableName)
+func (s *eventStore) outboxTableName() string {
+	return s.computeTableName(s.options.outboxStorageParams.tableName)
 }
@@ -9,6 +9,7 @@ import (
 )
 
 type Event struct {
+	Position         sql.NullInt64
 	ID               sql.NullString
 	Type             sql.NullString
 	OccurredAt       sql.NullTime
@@ -26,7 +27,7 @@ func FromEvent(event eventsource.Event) (*Event, error) {
 		return nil, err
 	}
 
-	metadata, err := eventsource.MarshalES(event.Metadata())
+	metadata, err := eventsource.MarshalES(eventsource.EventMetadata(event))
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +50,7 @@ func (e *Event) ToReadModel() eventsource.EventReadModel {
 	_ = json.Unmarshal(e.Metadata, &metadata)
 
 	return eventsource.EventReadModel{
+		Position:         e.Position.Int64,
 		ID:               eventsource.EventID(e.ID.String),
 		Type:             eventsource.EventType(e.Type.String),
 		OccurredAt:       e.OccurredAt.Time,
@@ -0,0 +1,727 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/thefabric-io/eventsource"
+	"github.com/thefabric-io/eventsource/filesystem"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dsnEnv names the environment variable giving the connection string of the
+// PostgreSQL database (13 or later) the integration tests run against. They
+// are skipped when it is not set.
+const dsnEnv = "EVENTSOURCE_POSTGRES_DSN"
+
+const counterType eventsource.AggregateType = "counter"
+
+type counter struct {
+	*eventsource.BaseAggregate
+	Value int `es:"value"`
+}
+
+func newCounter(id string) *counter {
+	return &counter{BaseAggregate: eventsource.InitAggregate(id, counterType)}
+}
+
+func (c *counter) Increment(ctx context.Context, by int) error {
+	return eventsource.Raise(ctx, c, &incremented{BaseEvent: eventsource.NewBaseEvent(c, nil), By: by})
+}
+
+func (c *counter) ParseEvents(_ context.Context, ee ...eventsource.EventReadModel) []eventsource.Event {
+	results := make([]eventsource.Event, 0, len(ee))
+	for _, e := range ee {
+		switch e.Type {
+		case incrementedType:
+			event := &incremented{BaseEvent: e.InitBaseEvent()}
+			if err := eventsource.UnmarshalES(e.Data, event); err != nil {
+				continue
+			}
+
+			results = append(results, event)
+		}
+	}
+
+	return results
+}
+
+const incrementedType eventsource.EventType = "incremented"
+
+type incremented struct {
+	*eventsource.BaseEvent
+	By int `es:"by"`
+}
+
+func (e *incremented) Type() eventsource.EventType {
+	return incrementedType
+}
+
+func (e *incremented) ApplyTo(_ context.Context, a eventsource.Aggregate) {
+	a.(*counter).Value += e.By
+}
+
+// integration is a schema of the database of dsnEnv, migrated for a test and
+// dropped when it ends.
+type integration struct {
+	dsn    string
+	schema string
+	db     *sqlx.DB
+}
+
+func newIntegration(t *testing.T) *integration {
+	t.Helper()
+
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", dsnEnv)
+	}
+
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	it := &integration{
+		dsn:    dsn,
+		schema: fmt.Sprintf("eventsource_test_%d", time.Now().UnixNano()),
+		db:     db,
+	}
+
+	t.Cleanup(func() {
+		_, _ = db.Exec(fmt.Sprintf("drop schema if exists %s cascade; ", it.schema))
+		_ = db.Close()
+	})
+
+	if err := Migrate(context.Background(), db, it.options().Build()); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	return it
+}
+
+// options returns a builder of the options of the schema of the test.
+func (it *integration) options() *OptionsBuilder {
+	return NewOptionsBuilder().WithSchemaName(it.schema)
+}
+
+func (it *integration) newStore(t *testing.T, options *Options) eventsource.EventStore {
+	t.Helper()
+
+	if options == nil {
+		options = it.options().Build()
+	}
+
+	store, err := NewEventStore(trace.NewNoopTracerProvider().Tracer(""), options)
+	if err != nil {
+		t.Fatalf("NewEventStore() error = %v", err)
+	}
+
+	return store
+}
+
+type driver struct {
+	name  string
+	begin eventsource.BeginFunc
+}
+
+// drivers returns the ways transactions are given to the stores: database/sql
+// through sqlx, and pgx. Each pgx transaction runs on a connection of its own,
+// closed when the test ends.
+func (it *integration) drivers(t *testing.T) []driver {
+	return []driver{
+		{
+			name: "sqlx",
+			begin: func(ctx context.Context) (eventsource.Transaction, error) {
+				return it.db.BeginTxx(ctx, nil)
+			},
+		},
+		{
+			name: "pgx",
+			begin: func(ctx context.Context) (eventsource.Transaction, error) {
+				conn, err := pgx.Connect(ctx, it.dsn)
+				if err != nil {
+					return nil, err
+				}
+				t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+				return PgxBeginFunc(conn)(ctx)
+			},
+		},
+	}
+}
+
+func begin(t *testing.T, b eventsource.BeginFunc) eventsource.Transaction {
+	t.Helper()
+
+	tx, err := b(context.Background())
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	t.Cleanup(func() { _ = tx.Rollback() })
+
+	return tx
+}
+
+func saveIncrements(t *testing.T, b eventsource.BeginFunc, store eventsource.EventStore, id string, n int, opts ...eventsource.SaveOption) {
+	t.Helper()
+
+	ctx := context.Background()
+	tx := begin(t, b)
+
+	c := newCounter(id)
+	if _, err := store.Load(ctx, tx, c); err != nil && !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		if err := c.Increment(ctx, 1); err != nil {
+			t.Fatalf("Increment() error = %v", err)
+		}
+	}
+
+	if err := store.Save(ctx, tx, c, opts...); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+}
+
+func TestEventStore_Save_Conflict(t *testing.T) {
+	it := newIntegration(t)
+	store := it.newStore(t, nil)
+
+	for _, d := range it.drivers(t) {
+		t.Run(d.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			tests := []struct {
+				name string
+				opts []eventsource.SaveOption
+			}{
+				{name: "unique version"},
+				{name: "expected version", opts: []eventsource.SaveOption{eventsource.ExpectVersion(2)}},
+			}
+
+			for i, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					id := fmt.Sprintf("c_%s_%d", d.name, i)
+					saveIncrements(t, d.begin, store, id, 2)
+
+					// Both transactions load the counter at the same version:
+					// the save committed second conflicts with the first one.
+					first, second := begin(t, d.begin), begin(t, d.begin)
+
+					counters := make([]*counter, 0, 2)
+					for _, tx := range []eventsource.Transaction{first, second} {
+						c := newCounter(id)
+						if _, err := store.Load(ctx, tx, c); err != nil {
+							t.Fatalf("Load() error = %v", err)
+						}
+
+						if err := c.Increment(ctx, 1); err != nil {
+							t.Fatalf("Increment() error = %v", err)
+						}
+
+						counters = append(counters, c)
+					}
+
+					const expected = 2
+
+					if err := store.Save(ctx, first, counters[0], tt.opts...); err != nil {
+						t.Fatalf("Save() error = %v", err)
+					}
+
+					if err := first.Commit(); err != nil {
+						t.Fatalf("Commit() error = %v", err)
+					}
+
+					var conflict *eventsource.ConcurrencyConflictError
+
+					err := store.Save(ctx, second, counters[1], tt.opts...)
+					if !errors.As(err, &conflict) {
+						t.Fatalf("Save() error = %v, want a *ConcurrencyConflictError", err)
+					}
+
+					if conflict.AggregateID != eventsource.AggregateID(id) || conflict.ExpectedVersion != expected || conflict.ActualVersion != expected+1 {
+						t.Errorf("Save() error = %+v, want '%s' expected at version %d but at %d", conflict, id, expected, expected+1)
+					}
+				})
+			}
+		})
+	}
+}
+
+func readAll(t *testing.T, b eventsource.BeginFunc, store eventsource.EventStore, fromPosition int64, opts ...eventsource.ReadOption) []eventsource.EventReadModel {
+	t.Helper()
+
+	tx := begin(t, b)
+	defer tx.Rollback()
+
+	ee, err := store.ReadAll(context.Background(), tx, fromPosition, opts...)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	return ee
+}
+
+func aggregateIDs(ee []eventsource.EventReadModel) []eventsource.AggregateID {
+	results := make([]eventsource.AggregateID, 0, len(ee))
+	for _, e := range ee {
+		results = append(results, e.AggregateID)
+	}
+
+	return results
+}
+
+func TestEventStore_ReadAll(t *testing.T) {
+	it := newIntegration(t)
+	store := it.newStore(t, nil)
+
+	for _, d := range it.drivers(t) {
+		t.Run(d.name, func(t *testing.T) {
+			ctx := context.Background()
+			ids := []string{"r1_" + d.name, "r2_" + d.name}
+
+			var from int64
+			if ee := readAll(t, d.begin, store, 0, eventsource.WithBatchSize(1000)); len(ee) > 0 {
+				from = ee[len(ee)-1].Position
+			}
+
+			saveIncrements(t, d.begin, store, ids[0], 3)
+			saveIncrements(t, d.begin, store, ids[1], 2)
+
+			// The events are read in pages of two, each following the last
+			// event of the previous one.
+			var pages [][]eventsource.EventReadModel
+			for position := from; ; {
+				ee := readAll(t, d.begin, store, position, eventsource.WithBatchSize(2))
+				if len(ee) == 0 {
+					break
+				}
+
+				pages = append(pages, ee)
+				position = ee[len(ee)-1].Position
+			}
+
+			var events []eventsource.EventReadModel
+			for _, page := range pages {
+				events = append(events, page...)
+			}
+
+			if len(pages) != 3 || len(events) != 5 {
+				t.Fatalf("ReadAll() read %d events in %d pages, want 5 in 3", len(events), len(pages))
+			}
+
+			for i, e := range events {
+				want := eventsource.AggregateVersion(i + 1)
+				if i >= 3 {
+					want = eventsource.AggregateVersion(i - 2)
+				}
+
+				if i > 0 && e.Position <= events[i-1].Position {
+					t.Errorf("ReadAll()[%d] at position %d, want after %d", i, e.Position, events[i-1].Position)
+				}
+
+				if wantID := ids[i/3]; e.AggregateID.String() != wantID || e.AggregateVersion != want {
+					t.Errorf("ReadAll()[%d] = '%s' at version %d, want '%s' at version %d", i, e.AggregateID, e.AggregateVersion, wantID, want)
+				}
+			}
+
+			filtered := readAll(t, d.begin, store, from, eventsource.WithAggregateTypes("other"))
+			if len(filtered) != 0 {
+				t.Errorf("ReadAll() with another aggregate type = %v, want none", aggregateIDs(filtered))
+			}
+
+			// An event positioned after the one of a running transaction is
+			// not read before that transaction ends.
+			from = events[len(events)-1].Position
+
+			first := begin(t, d.begin)
+
+			c := newCounter("r3_" + d.name)
+			if err := c.Increment(ctx, 1); err != nil {
+				t.Fatalf("Increment() error = %v", err)
+			}
+
+			if err := store.Save(ctx, first, c); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			saveIncrements(t, d.begin, store, "r4_"+d.name, 1)
+
+			if ee := readAll(t, d.begin, store, from); len(ee) != 0 {
+				t.Fatalf("ReadAll() = %v while an earlier transaction runs, want none", aggregateIDs(ee))
+			}
+
+			if err := first.Commit(); err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+
+			ee := readAll(t, d.begin, store, from)
+			if got := aggregateIDs(ee); len(got) != 2 || got[0].String() != "r3_"+d.name || got[1].String() != "r4_"+d.name {
+				t.Errorf("ReadAll() = %v, want r3 then r4", got)
+			}
+
+			if ee := readAll(t, d.begin, store, ee[len(ee)-1].Position); len(ee) != 0 {
+				t.Errorf("ReadAll() after the last event = %v, want none", aggregateIDs(ee))
+			}
+		})
+	}
+}
+
+func TestSubscriber_CatchUp(t *testing.T) {
+	it := newIntegration(t)
+	store := it.newStore(t, nil)
+
+	checkpoints, err := NewCheckpointStore(trace.NewNoopTracerProvider().Tracer(""), it.options().Build())
+	if err != nil {
+		t.Fatalf("NewCheckpointStore() error = %v", err)
+	}
+
+	for _, d := range it.drivers(t) {
+		t.Run(d.name, func(t *testing.T) {
+			ctx := context.Background()
+			name := "counters_" + d.name
+
+			subscriber := eventsource.NewSubscriber(store, checkpoints, d.begin, eventsource.WithReadOptions(eventsource.WithBatchSize(2)))
+
+			var handled []eventsource.EventReadModel
+
+			catchUp := func(t *testing.T) {
+				t.Helper()
+
+				if err := subscriber.CatchUp(ctx, name, 0, eventsource.EventHandlerFunc(func(_ context.Context, _ eventsource.Transaction, e eventsource.EventReadModel) error {
+					// The stream also holds the events of the other drivers.
+					if strings.HasSuffix(e.AggregateID.String(), "_"+d.name) {
+						handled = append(handled, e)
+					}
+
+					return nil
+				})); err != nil {
+					t.Fatalf("CatchUp() error = %v", err)
+				}
+			}
+
+			checkpoint := func(t *testing.T) int64 {
+				t.Helper()
+
+				// The checkpoint stays locked until the transaction ends.
+				tx := begin(t, d.begin)
+				defer tx.Rollback()
+
+				position, err := checkpoints.LoadCheckpoint(ctx, tx, name)
+				if err != nil {
+					t.Fatalf("LoadCheckpoint() error = %v", err)
+				}
+
+				return position
+			}
+
+			saveIncrements(t, d.begin, store, "s1_"+d.name, 3)
+			catchUp(t)
+
+			if len(handled) != 3 {
+				t.Fatalf("CatchUp() handled %d events, want 3", len(handled))
+			}
+
+			if got := checkpoint(t); got != handled[2].Position {
+				t.Errorf("LoadCheckpoint() = %d, want %d", got, handled[2].Position)
+			}
+
+			// The subscription resumes from its checkpoint and does not skip
+			// the events of a transaction committing after a later one.
+			first := begin(t, d.begin)
+
+			c := newCounter("s2_" + d.name)
+			if err := c.Increment(ctx, 1); err != nil {
+				t.Fatalf("Increment() error = %v", err)
+			}
+
+			if err := store.Save(ctx, first, c); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			saveIncrements(t, d.begin, store, "s3_"+d.name, 1)
+			catchUp(t)
+
+			if len(handled) != 3 {
+				t.Fatalf("CatchUp() handled %v while an earlier transaction runs, want no new event", aggregateIDs(handled[3:]))
+			}
+
+			if err := first.Commit(); err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+
+			catchUp(t)
+			catchUp(t)
+
+			if got := aggregateIDs(handled[3:]); len(got) != 2 || got[0] != c.ID() || got[1].String() != "s3_"+d.name {
+				t.Errorf("CatchUp() handled %v, want s2 then s3 once", got)
+			}
+
+			if got, want := checkpoint(t), handled[len(handled)-1].Position; got != want {
+				t.Errorf("LoadCheckpoint() = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      eventsource.RetentionPolicy
+		wantDeleted int64
+		wantKept    []eventsource.AggregateVersion
+	}{
+		{name: "keep last", policy: eventsource.KeepLast(3), wantDeleted: 5, wantKept: []eventsource.AggregateVersion{2, 3, 4}},
+		{name: "keep newer than", policy: eventsource.KeepNewerThan(time.Hour), wantDeleted: 10, wantKept: []eventsource.AggregateVersion{3, 4}},
+		{name: "keep all", policy: eventsource.RetentionPolicy{KeepLast: 1, MaxAge: 72 * time.Hour}, wantKept: []eventsource.AggregateVersion{1, 2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := newIntegration(t)
+
+			for _, d := range it.drivers(t) {
+				t.Run(d.name, func(t *testing.T) {
+					ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
+
+					options := it.options().Build()
+
+					snapshots, err := NewSnapshotStore(trace.NewNoopTracerProvider().Tracer(""), options)
+					if err != nil {
+						t.Fatalf("NewSnapshotStore() error = %v", err)
+					}
+
+					// Five aggregates, more than a chunk of two, have snapshots
+					// at versions 1 to 4, the first two taken two days ago. The
+					// policies keep what the runs of the other drivers kept.
+					tx := begin(t, d.begin)
+
+					for i := 1; i <= 5; i++ {
+						for v := 1; v <= 4; v++ {
+							takenAt := time.Now()
+							if v <= 2 {
+								takenAt = takenAt.Add(-48 * time.Hour)
+							}
+
+							if err := snapshots.SaveSnapshot(ctx, tx, eventsource.Snapshot{
+								AggregateID:      eventsource.AggregateID(fmt.Sprintf("c%d_%s", i, d.name)),
+								AggregateType:    counterType,
+								AggregateVersion: eventsource.AggregateVersion(v),
+								SchemaVersion:    1,
+								TakenAt:          takenAt,
+								Data:             []byte(`{}`),
+							}); err != nil {
+								t.Fatalf("SaveSnapshot() error = %v", err)
+							}
+						}
+					}
+
+					if err := tx.Commit(); err != nil {
+						t.Fatalf("Commit() error = %v", err)
+					}
+
+					deleted, err := PruneSnapshots(ctx, d.begin, options, tt.policy, eventsource.WithPruneChunkSize(2))
+					if err != nil {
+						t.Fatalf("PruneSnapshots() error = %v", err)
+					}
+
+					if deleted != tt.wantDeleted {
+						t.Errorf("PruneSnapshots() = %d, want %d", deleted, tt.wantDeleted)
+					}
+
+					for i := 1; i <= 5; i++ {
+						var kept []eventsource.AggregateVersion
+
+						id := fmt.Sprintf("c%d_%s", i, d.name)
+
+						query := fmt.Sprintf("select aggregate_version from %s.snapshots where aggregate_id = $1 order by aggregate_version; ", it.schema)
+						if err := it.db.SelectContext(ctx, &kept, query, id); err != nil {
+							t.Fatalf("Select() error = %v", err)
+						}
+
+						if fmt.Sprint(kept) != fmt.Sprint(tt.wantKept) {
+							t.Errorf("PruneSnapshots() kept versions %v of '%s', want %v", kept, id, tt.wantKept)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestEventStore_LoadAt(t *testing.T) {
+	it := newIntegration(t)
+	store := it.newStore(t, nil)
+
+	for _, d := range it.drivers(t) {
+		t.Run(d.name, func(t *testing.T) {
+			ctx := context.Background()
+			id := "l_" + d.name
+
+			// One event per save, apart in time, with a snapshot at version 3.
+			for i := 0; i < 5; i++ {
+				saveIncrements(t, d.begin, store, id, 1, eventsource.WithSnapshot(3))
+				time.Sleep(2 * time.Millisecond)
+			}
+
+			tx := begin(t, d.begin)
+
+			// The bounds are the occurrence times as stored, to the
+			// microsecond.
+			ee, err := store.EventsHistory(ctx, tx, id, counterType.String(), 1, 0)
+			if err != nil {
+				t.Fatalf("EventsHistory() error = %v", err)
+			}
+
+			if len(ee) != 5 {
+				t.Fatalf("EventsHistory() = %d events, want 5", len(ee))
+			}
+
+			at := func(v int) time.Time {
+				return ee[v-1].OccurredAt
+			}
+
+			tests := []struct {
+				name   string
+				target eventsource.LoadTarget
+				// want is the version loaded, 0 when the aggregate did not
+				// exist yet.
+				want eventsource.AggregateVersion
+			}{
+				{name: "version before the snapshot", target: eventsource.AtVersion(2), want: 2},
+				{name: "version at the snapshot", target: eventsource.AtVersion(3), want: 3},
+				{name: "version after the snapshot", target: eventsource.AtVersion(4), want: 4},
+				{name: "before the first event", target: eventsource.AtTime(at(1).Add(-time.Microsecond))},
+				{name: "at the first event", target: eventsource.AtTime(at(1)), want: 1},
+				{name: "before the snapshot event", target: eventsource.AtTime(at(3).Add(-time.Microsecond)), want: 2},
+				{name: "at the snapshot event", target: eventsource.AtTime(at(3)), want: 3},
+				{name: "after the snapshot event", target: eventsource.AtTime(at(3).Add(time.Microsecond)), want: 3},
+				{name: "at an event after the snapshot", target: eventsource.AtTime(at(4)), want: 4},
+				{name: "after the last event", target: eventsource.AtTime(at(5).Add(time.Microsecond)), want: 5},
+				{name: "version beyond the time", target: eventsource.LoadTarget{Version: 4, Time: at(2)}, want: 2},
+				{name: "time beyond the version", target: eventsource.LoadTarget{Version: 2, Time: at(4)}, want: 2},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					loaded, err := store.LoadAt(ctx, tx, newCounter(id), tt.target)
+					if tt.want == 0 {
+						if !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+							t.Errorf("LoadAt() error = %v, want %v", err, eventsource.ErrAggregateDoNotExist)
+						}
+
+						return
+					}
+
+					if err != nil {
+						t.Fatalf("LoadAt() error = %v", err)
+					}
+
+					if c := loaded.(*counter); c.Value != int(tt.want) || c.Version() != tt.want {
+						t.Errorf("LoadAt() = value %d at version %d, want %d", c.Value, c.Version(), tt.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestEventStore_LoadMany(t *testing.T) {
+	it := newIntegration(t)
+
+	snapshots, err := filesystem.NewSnapshotStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewSnapshotStore() error = %v", err)
+	}
+
+	stores := []struct {
+		name  string
+		store eventsource.EventStore
+	}{
+		{name: "snapshots table", store: it.newStore(t, nil)},
+		{name: "snapshot store", store: it.newStore(t, it.options().WithSnapshotStore(snapshots).Build())},
+	}
+
+	for i, s := range stores {
+		for _, d := range it.drivers(t) {
+			t.Run(s.name+"/"+d.name, func(t *testing.T) {
+				ctx := context.Background()
+				store := s.store
+				prefix := fmt.Sprintf("m%d_%s_", i, d.name)
+
+				saveIncrements(t, d.begin, store, prefix+"c1", 12)
+				saveIncrements(t, d.begin, store, prefix+"c1", 3)
+				saveIncrements(t, d.begin, store, prefix+"c2", 4)
+
+				// The snapshot at version 25 of a rolled back save outlives
+				// the transaction in the snapshot store.
+				tx := begin(t, d.begin)
+
+				c := newCounter(prefix + "c1")
+				if _, err := store.Load(ctx, tx, c); err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+
+				for i := 0; i < 10; i++ {
+					if err := c.Increment(ctx, 2); err != nil {
+						t.Fatalf("Increment() error = %v", err)
+					}
+				}
+
+				if err := store.Save(ctx, tx, c); err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+
+				if err := tx.Rollback(); err != nil {
+					t.Fatalf("Rollback() error = %v", err)
+				}
+
+				saveIncrements(t, d.begin, store, prefix+"c1", 10, eventsource.WithSnapshotStrategy(eventsource.NeverSnapshot()))
+
+				// The stream of c1 and its snapshots are not loaded for
+				// another type.
+				other := &counter{BaseAggregate: eventsource.InitAggregate(prefix+"c1", "other")}
+
+				results, err := store.LoadMany(ctx, begin(t, d.begin), newCounter(prefix+"c1"), newCounter(prefix+"missing"), newCounter(prefix+"c2"), other)
+				if err != nil {
+					t.Fatalf("LoadMany() error = %v", err)
+				}
+
+				if len(results) != 4 {
+					t.Fatalf("LoadMany() = %d results, want 4", len(results))
+				}
+
+				for _, i := range []int{1, 3} {
+					if !errors.Is(results[i].Err, eventsource.ErrAggregateDoNotExist) {
+						t.Errorf("LoadMany() result %d error = %v, want ErrAggregateDoNotExist", i, results[i].Err)
+					}
+				}
+
+				for i, want := range map[int]int{0: 25, 2: 4} {
+					if results[i].Err != nil {
+						t.Fatalf("LoadMany() result %d error = %v", i, results[i].Err)
+					}
+
+					if c := results[i].Aggregate.(*counter); c.Value != want || c.Version() != eventsource.AggregateVersion(want) {
+						t.Errorf("LoadMany() result %d = value %d at version %d, want %d", i, c.Value, c.Version(), want)
+					}
+				}
+			})
+		}
+	}
+}
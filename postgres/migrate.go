@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// TxBeginner starts the transaction migrations are applied in. It is
+// implemented by both *sql.DB and *sqlx.DB.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Migration is a versioned migration of the event store schema.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations returns the migrations of the event store schema rendered with
+// the schema and table names of the options, ordered by version.
+func Migrations(options *Options) ([]Migration, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	params := migrationParams{
+		Schema:           options.schemaName,
+		EventsTable:      options.eventStorageParams.tableName,
+		Events:           options.computeTableName(options.eventStorageParams.tableName),
+		SnapshotsTable:   options.snapshotStorageParams.tableName,
+		Snapshots:        options.computeTableName(options.snapshotStorageParams.tableName),
+		CheckpointsTable: options.checkpointStorageParams.tableName,
+		Checkpoints:      options.computeTableName(options.checkpointStorageParams.tableName),
+		OutboxTable:      options.outboxStorageParams.tableName,
+		Outbox:           options.computeTableName(options.outboxStorageParams.tableName),
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+
+		prefix, _, _ := strings.Cut(base, "_")
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name '%s': %w", name, err)
+		}
+
+		tmpl, err := template.ParseFS(migrationFiles, name)
+		if err != nil {
+			return nil, err
+		}
+
+		b := bytes.Buffer{}
+		if err := tmpl.Execute(&b, params); err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    base,
+			SQL:     b.String(),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+type migrationParams struct {
+	Schema           string
+	EventsTable      string
+	Events           string
+	SnapshotsTable   string
+	Snapshots        string
+	CheckpointsTable string
+	Checkpoints      string
+	OutboxTable      string
+	Outbox           string
+}
+
+// Migrate creates or upgrades the event store schema by applying, in a single
+// transaction, the migrations that were not applied yet. Applied versions are
+// tracked in the migrations table of the schema, and concurrent calls, e.g. by
+// several instances of a service starting at once, are serialized with an
+// advisory lock.
+func Migrate(ctx context.Context, db TxBeginner, options *Options) error {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := Migrations(options)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock($1);", migrationLockKey(options)); err != nil {
+		return err
+	}
+
+	migrationsTable := options.computeTableName(options.migrationStorageParams.tableName)
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("create schema if not exists %s; ", options.schemaName))
+	b.WriteString(fmt.Sprintf("create table if not exists %s ", migrationsTable))
+	b.WriteString("(version bigint primary key, name varchar, applied_at timestamptz); ")
+
+	if _, err := tx.ExecContext(ctx, b.String()); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx, tx, migrationsTable)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, exists := applied[m.Version]; exists {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+			return fmt.Errorf("could not apply migration '%s': %w", m.Name, err)
+		}
+
+		query := fmt.Sprintf("insert into %s (version, name, applied_at) values ($1, $2, $3); ", migrationsTable)
+		if _, err := tx.ExecContext(ctx, query, m.Version, m.Name, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func appliedMigrations(ctx context.Context, tx *sql.Tx, migrationsTable string) (map[int]struct{}, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("select version from %s; ", migrationsTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]struct{})
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = struct{}{}
+	}
+
+	return applied, rows.Err()
+}
+
+// migrationLockKey derives the advisory lock key from the schema so that
+// event stores living in different schemas are migrated independently.
+func migrationLockKey(options *Options) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("eventsource.postgres.Migrate:" + options.schemaName))
+
+	return int64(h.Sum64())
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	options := NewOptionsBuilder().
+		WithSchemaName("store").
+		WithEventStorageTableName("domain_events").
+		WithSnapshotStorageTableName("domain_snapshots").
+		Build()
+
+	migrations, err := Migrations(options)
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("Migrations() returned no migration")
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Migrations()[%d].Version = %d, want %d", i, m.Version, i+1)
+		}
+
+		if strings.Contains(m.SQL, "{{") || strings.Contains(m.SQL, "es.") {
+			t.Errorf("Migrations()[%d] is not rendered with the options:\n%s", i, m.SQL)
+		}
+	}
+
+	for _, want := range []string{
+		"create table if not exists store.domain_events",
+		"create table if not exists store.domain_snapshots",
+		"create sequence if not exists store.domain_events_position_seq",
+		"create table if not exists store.checkpoints",
+		"create table if not exists store.outbox",
+	} {
+		found := false
+		for _, m := range migrations {
+			found = found || strings.Contains(m.SQL, want)
+		}
+
+		if !found {
+			t.Errorf("Migrations() does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+create table if not exists {{.Events}}
+(
+    id                varchar primary key,
+    type              varchar,
+    occurred_at       timestamptz,
+    registered_at     timestamptz,
+    aggregate_id      varchar,
+    aggregate_type    varchar,
+    aggregate_version bigint,
+    data              jsonb,
+    metadata          jsonb,
+    unique (aggregate_id, aggregate_version)
+);
+
+create table if not exists {{.Snapshots}}
+(
+    aggregate_id      varchar,
+    aggregate_type    varchar,
+    aggregate_version bigint,
+    taken_at          timestamptz,
+    registered_at     timestamptz,
+    data              jsonb,
+    primary key (aggregate_id, aggregate_version)
+);
@@ -0,0 +1,18 @@
+-- Events stored before the global stream existed are positioned in the order
+-- they were registered.
+alter table {{.Events}} add column if not exists position bigint;
+
+create sequence if not exists {{.Schema}}.{{.EventsTable}}_position_seq owned by {{.Events}}.position;
+
+update {{.Events}} e
+set position = o.position
+from (select id, row_number() over (order by registered_at, aggregate_id, aggregate_version) as position from {{.Events}}) o
+where e.id = o.id
+  and e.position is null;
+
+select setval('{{.Schema}}.{{.EventsTable}}_position_seq', coalesce((select max(position) from {{.Events}}), 0) + 1, false);
+
+alter table {{.Events}} alter column position set default nextval('{{.Schema}}.{{.EventsTable}}_position_seq');
+alter table {{.Events}} alter column position set not null;
+
+create unique index if not exists {{.EventsTable}}_position_idx on {{.Events}} (position);
@@ -0,0 +1,6 @@
+create table if not exists {{.Checkpoints}}
+(
+    name       varchar primary key,
+    position   bigint not null,
+    updated_at timestamptz
+);
@@ -0,0 +1,19 @@
+create table if not exists {{.Outbox}}
+(
+    id              varchar primary key,
+    topic           varchar,
+    key             varchar,
+    event_id        varchar,
+    event_type      varchar,
+    aggregate_id    varchar,
+    aggregate_type  varchar,
+    payload         bytea,
+    metadata        jsonb,
+    created_at      timestamptz,
+    published_at    timestamptz,
+    attempts        int not null default 0,
+    next_attempt_at timestamptz,
+    last_error      varchar
+);
+
+create index if not exists {{.OutboxTable}}_unpublished_idx on {{.Outbox}} (created_at) where published_at is null;
@@ -0,0 +1,5 @@
+alter table {{.Snapshots}}
+    add column if not exists schema_version integer not null default 1;
+
+create index if not exists {{.SnapshotsTable}}_aggregate_type_schema_version_idx
+    on {{.Snapshots}} (aggregate_type, schema_version);
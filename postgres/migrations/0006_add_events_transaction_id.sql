@@ -0,0 +1,15 @@
+-- Positions are allocated on insert, so a transaction committing after a
+-- concurrent one may make a lower position visible later. The id of the
+-- inserting transaction lets ReadAll return only the events of the
+-- transactions that ended, in an order later commits cannot go back on.
+-- Events stored before are ordered by position ahead of the others.
+alter table {{.Events}} add column if not exists transaction_id xid8;
+
+update {{.Events}}
+set transaction_id = '0'::xid8
+where transaction_id is null;
+
+alter table {{.Events}} alter column transaction_id set default pg_current_xact_id();
+alter table {{.Events}} alter column transaction_id set not null;
+
+create index if not exists {{.EventsTable}}_transaction_id_position_idx on {{.Events}} (transaction_id, position);
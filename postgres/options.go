@@ -3,26 +3,44 @@ package postgres
 import (
 	"fmt"
 	"strings"
+
+	"github.com/thefabric-io/eventsource"
 )
 
 func DefaultOptions() *Options {
 	return &Options{
-		schemaName:            defaultSchemaName(),
-		eventStorageParams:    defaultEventStorageParams(),
-		snapshotStorageParams: defaultSnapshotStorageParams(),
+		schemaName:              defaultSchemaName(),
+		eventStorageParams:      defaultEventStorageParams(),
+		snapshotStorageParams:   defaultSnapshotStorageParams(),
+		checkpointStorageParams: defaultCheckpointStorageParams(),
+		outboxStorageParams:     defaultOutboxStorageParams(),
+		migrationStorageParams:  defaultMigrationStorageParams(),
 	}
 }
 
 type Options struct {
-	schemaName            string
-	eventStorageParams    eventStorageParams
-	snapshotStorageParams snapshotStorageParams
+	schemaName              string
+	eventStorageParams      eventStorageParams
+	snapshotStorageParams   snapshotStorageParams
+	checkpointStorageParams checkpointStorageParams
+	outboxStorageParams     outboxStorageParams
+	migrationStorageParams  migrationStorageParams
+	logger                  eventsource.Logger
+	snapshotStore           eventsource.SnapshotStore
+	retention               *eventsource.RetentionPolicy
+	registry                *eventsource.Registry
+	upcasters               *eventsource.Upcasters
+	projections             []eventsource.Projection
+	outboxMapper            eventsource.OutboxMapper
 }
 
 func (o *Options) Validate() error {
 	if len(strings.TrimSpace(o.schemaName)) == 0 ||
 		len(strings.TrimSpace(o.eventStorageParams.tableName)) == 0 ||
-		len(strings.TrimSpace(o.snapshotStorageParams.tableName)) == 0 {
+		len(strings.TrimSpace(o.snapshotStorageParams.tableName)) == 0 ||
+		len(strings.TrimSpace(o.checkpointStorageParams.tableName)) == 0 ||
+		len(strings.TrimSpace(o.outboxStorageParams.tableName)) == 0 ||
+		len(strings.TrimSpace(o.migrationStorageParams.tableName)) == 0 {
 		return fmt.Errorf("options invalid")
 	}
 
@@ -30,7 +48,52 @@ func (o *Options) Validate() error {
 }
 
 func (o *Options) IsZero() bool {
-	return *o == Options{}
+	return o.schemaName == "" &&
+		o.eventStorageParams == eventStorageParams{} &&
+		o.snapshotStorageParams == snapshotStorageParams{} &&
+		o.checkpointStorageParams == checkpointStorageParams{} &&
+		o.outboxStorageParams == outboxStorageParams{} &&
+		o.migrationStorageParams == migrationStorageParams{} &&
+		o.logger == nil &&
+		o.snapshotStore == nil &&
+		o.retention == nil &&
+		o.registry == nil &&
+		o.upcasters == nil &&
+		len(o.projections) == 0 &&
+		o.outboxMapper == nil
+}
+
+func (o *Options) computeTableName(tableName string) string {
+	schema := o.schemaName
+	if schema == "" {
+		return tableName
+	}
+
+	return fmt.Sprintf("%s.%s", schema, tableName)
+}
+
+// splitTableName splits a table name computed by computeTableName into its
+// schema and table parts.
+func splitTableName(name string) []string {
+	return strings.SplitN(name, ".", 2)
+}
+
+// prepareOptions returns the options to use for a store, defaulting them when
+// none are given.
+func prepareOptions(options *Options) (*Options, error) {
+	if options == nil || options.IsZero() {
+		options = DefaultOptions()
+	}
+
+	if len(strings.TrimSpace(options.schemaName)) == 0 {
+		options.schemaName = defaultSchemaName()
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	return options, nil
 }
 
 func NewOptionsBuilder() *OptionsBuilder {
@@ -59,6 +122,81 @@ func (b *OptionsBuilder) WithSnapshotStorageTableName(name string) *OptionsBuild
 	return b
 }
 
+func (b *OptionsBuilder) WithCheckpointStorageTableName(name string) *OptionsBuilder {
+	b.options.checkpointStorageParams.tableName = name
+
+	return b
+}
+
+func (b *OptionsBuilder) WithOutboxStorageTableName(name string) *OptionsBuilder {
+	b.options.outboxStorageParams.tableName = name
+
+	return b
+}
+
+func (b *OptionsBuilder) WithMigrationStorageTableName(name string) *OptionsBuilder {
+	b.options.migrationStorageParams.tableName = name
+
+	return b
+}
+
+// WithLogger makes the stores log through l rather than the global logger,
+// including while replaying the aggregates they load.
+func (b *OptionsBuilder) WithLogger(l eventsource.Logger) *OptionsBuilder {
+	b.options.logger = l
+
+	return b
+}
+
+// WithSnapshotStore makes the event store keep the snapshots in ss rather than
+// in the snapshots table.
+func (b *OptionsBuilder) WithSnapshotStore(ss eventsource.SnapshotStore) *OptionsBuilder {
+	b.options.snapshotStore = ss
+
+	return b
+}
+
+// WithSnapshotRetention makes the event store prune the snapshots of an
+// aggregate that the policy does not keep whenever Save snapshots it. It only
+// applies to the snapshots kept in the snapshots table.
+func (b *OptionsBuilder) WithSnapshotRetention(policy eventsource.RetentionPolicy) *OptionsBuilder {
+	b.options.retention = &policy
+
+	return b
+}
+
+// WithEventRegistry makes the event store decode loaded events with the
+// registry instead of the aggregate's ParseEvents.
+func (b *OptionsBuilder) WithEventRegistry(r *eventsource.Registry) *OptionsBuilder {
+	b.options.registry = r
+
+	return b
+}
+
+// WithUpcasters makes the event store upcast the events it reads to their
+// latest schema version.
+func (b *OptionsBuilder) WithUpcasters(u *eventsource.Upcasters) *OptionsBuilder {
+	b.options.upcasters = u
+
+	return b
+}
+
+// WithInlineProjections makes the event store project the saved events within
+// the transaction of Save.
+func (b *OptionsBuilder) WithInlineProjections(projections ...eventsource.Projection) *OptionsBuilder {
+	b.options.projections = append(b.options.projections, projections...)
+
+	return b
+}
+
+// WithOutbox makes the event store write the messages derived from the saved
+// events by the mapper to the outbox within the transaction of Save.
+func (b *OptionsBuilder) WithOutbox(mapper eventsource.OutboxMapper) *OptionsBuilder {
+	b.options.outboxMapper = mapper
+
+	return b
+}
+
 func (b *OptionsBuilder) Build() *Options {
 	return b.options
 }
@@ -84,3 +222,33 @@ func defaultSnapshotStorageParams() snapshotStorageParams {
 type snapshotStorageParams struct {
 	tableName string
 }
+
+func defaultCheckpointStorageParams() checkpointStorageParams {
+	return checkpointStorageParams{
+		tableName: "checkpoints",
+	}
+}
+
+type checkpointStorageParams struct {
+	tableName string
+}
+
+func defaultOutboxStorageParams() outboxStorageParams {
+	return outboxStorageParams{
+		tableName: "outbox",
+	}
+}
+
+type outboxStorageParams struct {
+	tableName string
+}
+
+func defaultMigrationStorageParams() migrationStorageParams {
+	return migrationStorageParams{
+		tableName: "schema_migrations",
+	}
+}
+
+type migrationStorageParams struct {
+	tableName string
+}
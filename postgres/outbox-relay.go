@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewOutboxRelay returns a relay publishing the messages of the outbox table
+// with the publisher. Several relays can run concurrently on the same table:
+// each claims its batch of messages with `for update skip locked`.
+func NewOutboxRelay(tracer trace.Tracer, options *Options, begin eventsource.BeginFunc, publisher eventsource.Publisher, opts ...eventsource.RelayOption) (*OutboxRelay, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxRelay{
+		options:      options,
+		relayOptions: eventsource.NewRelayOptions(opts...),
+		tracer:       tracer,
+		begin:        begin,
+		publisher:    publisher,
+	}, nil
+}
+
+type OutboxRelay struct {
+	options      *Options
+	relayOptions *eventsource.RelayOptions
+	tracer       trace.Tracer
+	begin        eventsource.BeginFunc
+	publisher    eventsource.Publisher
+}
+
+// Run relays the messages of the outbox until the context is done.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	for {
+		claimed, err := r.RelayBatch(ctx)
+		if err != nil {
+			return err
+		}
+
+		if claimed >= r.relayOptions.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.relayOptions.PollInterval):
+		}
+	}
+}
+
+// RelayBatch claims a batch of messages due for publishing, publishes them and
+// records the outcome of each attempt. It returns the number of messages
+// claimed.
+func (r *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	ctx, span := r.tracer.Start(ctx, "eventsource.postgres.OutboxRelay.RelayBatch")
+	defer span.End()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	t, err := r.begin(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	tx, err := connFor(t)
+	if err != nil {
+		_ = t.Rollback()
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	claimed, err := r.relayBatch(ctx, tx)
+	if err != nil {
+		_ = t.Rollback()
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	if err := t.Commit(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	return claimed, nil
+}
+
+func (r *OutboxRelay) relayBatch(ctx context.Context, tx conn) (int, error) {
+	messages, err := r.claim(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range messages {
+		if err := r.publisher.Publish(ctx, m); err != nil {
+			if err := r.markFailed(ctx, tx, m, err); err != nil {
+				return 0, err
+			}
+
+			continue
+		}
+
+		if err := r.markPublished(ctx, tx, m); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(messages), nil
+}
+
+func (r *OutboxRelay) claim(ctx context.Context, tx conn) ([]eventsource.OutboxMessage, error) {
+	b := strings.Builder{}
+
+	b.WriteString("select id, topic, key, event_id, event_type, aggregate_id, aggregate_type, payload, metadata, created_at, attempts ")
+	b.WriteString(fmt.Sprintf("from %s ", r.outboxTableName()))
+	b.WriteString("where published_at is null ")
+	b.WriteString("and (next_attempt_at is null or next_attempt_at <= $1) ")
+
+	args := []any{
+		time.Now().UTC(),
+	}
+
+	if r.relayOptions.MaxAttempts > 0 {
+		args = append(args, r.relayOptions.MaxAttempts)
+		b.WriteString(fmt.Sprintf("and attempts < $%d ", len(args)))
+	}
+
+	args = append(args, r.relayOptions.BatchSize)
+	b.WriteString("order by created_at ")
+	b.WriteString(fmt.Sprintf("limit $%d ", len(args)))
+	b.WriteString("for update skip locked; ")
+
+	rows, err := tx.query(ctx, b.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]eventsource.OutboxMessage, 0)
+	for rows.Next() {
+		var m OutboxMessage
+		if err := rows.Scan(
+			&m.ID,
+			&m.Topic,
+			&m.Key,
+			&m.EventID,
+			&m.EventType,
+			&m.AggregateID,
+			&m.AggregateType,
+			&m.Payload,
+			&m.Metadata,
+			&m.CreatedAt,
+			&m.Attempts,
+		); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, m.ToOutboxMessage())
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (r *OutboxRelay) markPublished(ctx context.Context, tx conn, m eventsource.OutboxMessage) error {
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("update %s ", r.outboxTableName()))
+	b.WriteString("set published_at = $1, attempts = attempts + 1, last_error = null ")
+	b.WriteString("where id = $2; ")
+
+	_, err := tx.exec(ctx, b.String(), time.Now().UTC(), m.ID.String())
+
+	return err
+}
+
+func (r *OutboxRelay) markFailed(ctx context.Context, tx conn, m eventsource.OutboxMessage, publishErr error) error {
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("update %s ", r.outboxTableName()))
+	b.WriteString("set attempts = attempts + 1, next_attempt_at = $1, last_error = $2 ")
+	b.WriteString("where id = $3; ")
+
+	nextAttemptAt := time.Now().UTC().Add(r.relayOptions.Backoff(m.Attempts + 1))
+
+	_, err := tx.exec(ctx, b.String(), nextAttemptAt, publishErr.Error(), m.ID.String())
+
+	return err
+}
+
+func (r *OutboxRelay) outboxTableName() string {
+	return r.options.computeTableName(r.options.outboxStorageParams.tableName)
+}
@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeConn records the statements run on it and answers the queries with the
+// rows it is given.
+type fakeConn struct {
+	rows  [][]any
+	calls []fakeCall
+}
+
+type fakeCall struct {
+	query string
+	args  []any
+}
+
+func (c *fakeConn) exec(_ context.Context, query string, args ...any) (int64, error) {
+	c.calls = append(c.calls, fakeCall{query: query, args: args})
+
+	return 1, nil
+}
+
+func (c *fakeConn) query(_ context.Context, query string, args ...any) (rows, error) {
+	c.calls = append(c.calls, fakeCall{query: query, args: args})
+
+	return &fakeRows{rows: c.rows}, nil
+}
+
+func (c *fakeConn) queryRow(_ context.Context, query string, args ...any) row {
+	c.calls = append(c.calls, fakeCall{query: query, args: args})
+
+	return &fakeRows{}
+}
+
+func (c *fakeConn) execBatch(ctx context.Context, statements ...statement) error {
+	for _, s := range statements {
+		if _, err := c.exec(ctx, s.query, s.args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *fakeConn) insertEvents(context.Context, string, []*Event) (map[string]int64, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) array(values []string) any {
+	return values
+}
+
+type fakeRows struct {
+	rows [][]any
+	next int
+}
+
+func (r *fakeRows) Next() bool {
+	r.next++
+
+	return r.next <= len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	if r.next == 0 || r.next > len(r.rows) {
+		return errors.New("no rows")
+	}
+
+	for i, v := range r.rows[r.next-1] {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+
+	return nil
+}
+
+func (r *fakeRows) Err() error {
+	return nil
+}
+
+func (r *fakeRows) Close() {}
+
+func outboxRow(t *testing.T, m eventsource.OutboxMessage) []any {
+	t.Helper()
+
+	sqlMessage, err := FromOutboxMessage(m)
+	if err != nil {
+		t.Fatalf("FromOutboxMessage() error = %v", err)
+	}
+
+	return []any{
+		sqlMessage.ID,
+		sqlMessage.Topic,
+		sqlMessage.Key,
+		sqlMessage.EventID,
+		sqlMessage.EventType,
+		sqlMessage.AggregateID,
+		sqlMessage.AggregateType,
+		sqlMessage.Payload,
+		sqlMessage.Metadata,
+		sqlMessage.CreatedAt,
+		sqlMessage.Attempts,
+	}
+}
+
+func TestOutboxRelay_claim(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []eventsource.RelayOption
+		want     []string
+		notWant  []string
+		wantArgs []any
+	}{
+		{
+			name:     "max attempts",
+			opts:     []eventsource.RelayOption{eventsource.WithRelayBatchSize(5), eventsource.WithMaxAttempts(3)},
+			want:     []string{"where published_at is null ", "and attempts < $2 ", "limit $3 ", "for update skip locked"},
+			wantArgs: []any{3, 5},
+		},
+		{
+			name:     "unlimited attempts",
+			opts:     []eventsource.RelayOption{eventsource.WithRelayBatchSize(5), eventsource.WithMaxAttempts(0)},
+			want:     []string{"where published_at is null ", "limit $2 ", "for update skip locked"},
+			notWant:  []string{"attempts <"},
+			wantArgs: []any{5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relay, err := NewOutboxRelay(trace.NewNoopTracerProvider().Tracer(""), nil, nil, nil, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewOutboxRelay() error = %v", err)
+			}
+
+			tx := &fakeConn{}
+			if _, err := relay.claim(context.Background(), tx); err != nil {
+				t.Fatalf("claim() error = %v", err)
+			}
+
+			call := tx.calls[0]
+			for _, want := range tt.want {
+				if !strings.Contains(call.query, want) {
+					t.Errorf("claim() query = %q, want it to contain %q", call.query, want)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if strings.Contains(call.query, notWant) {
+					t.Errorf("claim() query = %q, want it not to contain %q", call.query, notWant)
+				}
+			}
+
+			if !reflect.DeepEqual(call.args[1:], tt.wantArgs) {
+				t.Errorf("claim() args = %v, want %v after the due time", call.args[1:], tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestOutboxRelay_relayBatch(t *testing.T) {
+	ctx := context.Background()
+
+	published := eventsource.OutboxMessage{ID: "msg_1", Topic: "orders", Key: "o1", Attempts: 0, CreatedAt: time.Now()}
+	failing := eventsource.OutboxMessage{ID: "msg_2", Topic: "orders", Key: "o2", Attempts: 2, CreatedAt: time.Now()}
+
+	var handed []eventsource.MessageID
+
+	publisher := eventsource.PublisherFunc(func(_ context.Context, m eventsource.OutboxMessage) error {
+		handed = append(handed, m.ID)
+		if m.ID == failing.ID {
+			return errors.New("broker unavailable")
+		}
+
+		return nil
+	})
+
+	relay, err := NewOutboxRelay(trace.NewNoopTracerProvider().Tracer(""), nil, nil, publisher,
+		eventsource.WithRelayBackoff(func(attempts int) time.Duration { return time.Duration(attempts) * time.Minute }),
+	)
+	if err != nil {
+		t.Fatalf("NewOutboxRelay() error = %v", err)
+	}
+
+	tx := &fakeConn{rows: [][]any{outboxRow(t, published), outboxRow(t, failing)}}
+
+	before := time.Now().UTC()
+
+	claimed, err := relay.relayBatch(ctx, tx)
+	if err != nil {
+		t.Fatalf("relayBatch() error = %v", err)
+	}
+
+	if claimed != 2 || !reflect.DeepEqual(handed, []eventsource.MessageID{published.ID, failing.ID}) {
+		t.Fatalf("relayBatch() = %d claimed, handed %v, want 2 claimed, handed %v", claimed, handed, []eventsource.MessageID{published.ID, failing.ID})
+	}
+
+	if len(tx.calls) != 3 {
+		t.Fatalf("relayBatch() ran %d statements, want 3", len(tx.calls))
+	}
+
+	markPublished := tx.calls[1]
+	if !strings.Contains(markPublished.query, "set published_at = $1, attempts = attempts + 1, last_error = null ") || markPublished.args[1] != "msg_1" {
+		t.Errorf("relayBatch() marked the published message with %q %v", markPublished.query, markPublished.args)
+	}
+
+	markFailed := tx.calls[2]
+	if !strings.Contains(markFailed.query, "set attempts = attempts + 1, next_attempt_at = $1, last_error = $2 ") || markFailed.args[1] != "broker unavailable" || markFailed.args[2] != "msg_2" {
+		t.Fatalf("relayBatch() marked the failed message with %q %v", markFailed.query, markFailed.args)
+	}
+
+	// The failed message has made its third attempt: the next one is delayed
+	// by the backoff of three attempts.
+	nextAttemptAt := markFailed.args[0].(time.Time)
+	if delay := nextAttemptAt.Sub(before); delay < 3*time.Minute || delay > 3*time.Minute+time.Second {
+		t.Errorf("relayBatch() delayed the next attempt by %s, want 3m", delay)
+	}
+}
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+func FromOutboxMessage(m eventsource.OutboxMessage) (*OutboxMessage, error) {
+	metadata, err := eventsource.MarshalES(m.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxMessage{
+		ID:            sql.NullString{String: m.ID.String(), Valid: !m.ID.IsZero()},
+		Topic:         sql.NullString{String: m.Topic, Valid: m.Topic != ""},
+		Key:           sql.NullString{String: m.Key, Valid: m.Key != ""},
+		EventID:       sql.NullString{String: m.EventID.String(), Valid: !m.EventID.IsZero()},
+		EventType:     sql.NullString{String: m.EventType.String(), Valid: !m.EventType.IsZero()},
+		AggregateID:   sql.NullString{String: m.AggregateID.String(), Valid: !m.AggregateID.IsZero()},
+		AggregateType: sql.NullString{String: m.AggregateType.String(), Valid: !m.AggregateType.IsZero()},
+		Payload:       m.Payload,
+		Metadata:      metadata,
+		CreatedAt:     sql.NullTime{Time: m.CreatedAt, Valid: !m.CreatedAt.IsZero()},
+		Attempts:      sql.NullInt64{Int64: int64(m.Attempts), Valid: true},
+	}, nil
+}
+
+type OutboxMessage struct {
+	ID            sql.NullString
+	Topic         sql.NullString
+	Key           sql.NullString
+	EventID       sql.NullString
+	EventType     sql.NullString
+	AggregateID   sql.NullString
+	AggregateType sql.NullString
+	Payload       []byte
+	Metadata      json.RawMessage
+	CreatedAt     sql.NullTime
+	Attempts      sql.NullInt64
+}
+
+func (m *OutboxMessage) ToOutboxMessage() eventsource.OutboxMessage {
+	var metadata eventsource.Metadata
+	_ = json.Unmarshal(m.Metadata, &metadata)
+
+	return eventsource.OutboxMessage{
+		ID:            eventsource.MessageID(m.ID.String),
+		Topic:         m.Topic.String,
+		Key:           m.Key.String,
+		EventID:       eventsource.EventID(m.EventID.String),
+		EventType:     eventsource.EventType(m.EventType.String),
+		AggregateID:   eventsource.AggregateID(m.AggregateID.String),
+		AggregateType: eventsource.AggregateType(m.AggregateType.String),
+		Payload:       m.Payload,
+		Metadata:      metadata,
+		CreatedAt:     m.CreatedAt.Time,
+		Attempts:      int(m.Attempts.Int64),
+	}
+}
@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/thefabric-io/eventsource"
+)
+
+// pgxTransaction is implemented by the transactions the stores run natively
+// on pgx.
+type pgxTransaction interface {
+	eventsource.Transaction
+	PgxTx() pgx.Tx
+}
+
+// PgxBeginner starts pgx transactions. It is implemented by *pgxpool.Pool and
+// *pgx.Conn.
+type PgxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// PgxBeginFunc returns an eventsource.BeginFunc starting pgx transactions,
+// e.g. for subscriptions and outbox relays.
+func PgxBeginFunc(db PgxBeginner) eventsource.BeginFunc {
+	return func(ctx context.Context) (eventsource.Transaction, error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewPgxTransaction(ctx, tx), nil
+	}
+}
+
+// NewPgxTransaction turns a pgx transaction into an eventsource.Transaction
+// the stores run their queries on with pgx. The context is the one used to
+// commit or roll back the transaction.
+func NewPgxTransaction(ctx context.Context, tx pgx.Tx) *PgxTransaction {
+	return &PgxTransaction{ctx: ctx, tx: tx}
+}
+
+type PgxTransaction struct {
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func (t *PgxTransaction) Commit() error {
+	return t.tx.Commit(t.ctx)
+}
+
+func (t *PgxTransaction) Rollback() error {
+	return t.tx.Rollback(t.ctx)
+}
+
+func (t *PgxTransaction) PgxTx() pgx.Tx {
+	return t.tx
+}
+
+type pgxConn struct {
+	tx pgx.Tx
+}
+
+func (c *pgxConn) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	tag, err := c.tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (c *pgxConn) query(ctx context.Context, query string, args ...any) (rows, error) {
+	r, err := c.tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (c *pgxConn) queryRow(ctx context.Context, query string, args ...any) row {
+	return pgxRow{row: c.tx.QueryRow(ctx, query, args...)}
+}
+
+func (c *pgxConn) execBatch(ctx context.Context, statements ...statement) error {
+	if len(statements) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, s := range statements {
+		batch.Queue(s.query, s.args...)
+	}
+
+	return c.tx.SendBatch(ctx, batch).Close()
+}
+
+// insertEvents copies the events within a savepoint, so that a conflicting
+// version leaves the transaction usable to report the actual version.
+func (c *pgxConn) insertEvents(ctx context.Context, table string, events []*Event) (map[string]int64, error) {
+	savepoint, err := c.tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]any, 0, len(events))
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		values = append(values, e.insertValues())
+		ids = append(ids, e.ID.String)
+	}
+
+	if _, err := savepoint.CopyFrom(ctx, pgx.Identifier(splitTableName(table)), eventInsertColumns, pgx.CopyFromRows(values)); err != nil {
+		_ = savepoint.Rollback(ctx)
+
+		if isUniqueViolation(err) {
+			return nil, errVersionConflict
+		}
+
+		return nil, err
+	}
+
+	if err := savepoint.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	query := "select id, position from " + table + " where id = any($1); "
+
+	return scanPositions(c.query(ctx, query, ids))
+}
+
+func (c *pgxConn) array(values []string) any {
+	return values
+}
+
+// pgxRow reports missing rows with sql.ErrNoRows like database/sql does.
+type pgxRow struct {
+	row pgx.Row
+}
+
+func (r pgxRow) Scan(dest ...any) error {
+	if err := r.row.Scan(dest...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sql.ErrNoRows
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+const uniqueViolation = "23505"
@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+// PruneSnapshots deletes the snapshots that the policy does not keep and
+// returns their number. Aggregates are processed in the order of their ids,
+// a chunk of them per transaction, so that it can run on large tables without
+// holding long locks. begin starts the transaction of each chunk, on
+// database/sql or pgx.
+func PruneSnapshots(ctx context.Context, begin eventsource.BeginFunc, options *Options, policy eventsource.RetentionPolicy, opts ...eventsource.PruneOption) (int64, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return 0, err
+	}
+
+	pruneOptions := eventsource.NewPruneOptions(opts...)
+	table := options.computeTableName(options.snapshotStorageParams.tableName)
+
+	var (
+		total  int64
+		cursor string
+	)
+
+	for {
+		deleted, last, err := pruneSnapshotsChunk(ctx, begin, table, policy, cursor, pruneOptions.ChunkSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+
+		if last == "" {
+			return total, nil
+		}
+
+		cursor = last
+	}
+}
+
+// pruneSnapshotsChunk prunes the snapshots of the chunk of aggregates following
+// the cursor and returns the id of the last of them, or an empty id when there
+// are none left.
+func pruneSnapshotsChunk(ctx context.Context, begin eventsource.BeginFunc, table string, policy eventsource.RetentionPolicy, cursor string, size int) (int64, string, error) {
+	t, err := begin(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() {
+		_ = t.Rollback()
+	}()
+
+	c, err := connFor(t)
+	if err != nil {
+		return 0, "", err
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString("select distinct aggregate_id ")
+	b.WriteString(fmt.Sprintf("from %s ", table))
+	b.WriteString("where aggregate_id > $1 ")
+	b.WriteString("order by aggregate_id ")
+	b.WriteString("limit $2; ")
+
+	rows, err := c.query(ctx, b.String(), cursor, size)
+	if err != nil {
+		return 0, "", err
+	}
+
+	ids := make([]string, 0, size)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+
+			return 0, "", err
+		}
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+
+	if len(ids) == 0 {
+		return 0, "", t.Commit()
+	}
+
+	stmt := pruneStatement(table, c.array(ids), policy, time.Now().UTC())
+
+	deleted, err := c.exec(ctx, stmt.query, stmt.args...)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := t.Commit(); err != nil {
+		return 0, "", err
+	}
+
+	return deleted, ids[len(ids)-1], nil
+}
+
+// pruneStatement deletes the snapshots of the aggregates with the given ids
+// that the policy does not keep.
+func pruneStatement(table string, ids any, policy eventsource.RetentionPolicy, now time.Time) statement {
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("delete from %s ", table))
+	b.WriteString("where (aggregate_id, aggregate_version) in (")
+	b.WriteString("select aggregate_id, aggregate_version from (")
+	b.WriteString("select aggregate_id, aggregate_version, taken_at, ")
+	b.WriteString("row_number() over (partition by aggregate_id order by aggregate_version desc) as rank ")
+	b.WriteString(fmt.Sprintf("from %s ", table))
+	b.WriteString("where aggregate_id = any($1)")
+	b.WriteString(") ranked ")
+	b.WriteString("where rank > $2")
+
+	args := []any{
+		ids,
+		policy.KeptNewest(),
+	}
+
+	if cutoff := policy.Cutoff(now); !cutoff.IsZero() {
+		args = append(args, cutoff)
+		b.WriteString(" and taken_at < $3")
+	}
+
+	b.WriteString("); ")
+
+	return statement{query: b.String(), args: args}
+}
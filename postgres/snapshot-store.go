@@ -0,0 +1,314 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/thefabric-io/eventsource"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewSnapshotStore returns an eventsource.SnapshotStore keeping the snapshots
+// in the snapshots table of the schema. It is the snapshot store of the event
+// store unless the options are given another one with WithSnapshotStore.
+func NewSnapshotStore(tracer trace.Tracer, options *Options) (eventsource.SnapshotStore, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshotStore{
+		options: options,
+		tracer:  tracer,
+	}, nil
+}
+
+type snapshotStore struct {
+	options *Options
+	tracer  trace.Tracer
+}
+
+func (s *snapshotStore) SaveSnapshot(ctx context.Context, t eventsource.Transaction, snapshot eventsource.Snapshot) error {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.snapshotStore.SaveSnapshot")
+	defer span.End()
+
+	tx, err := connFor(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	stmt, err := s.insertStatement(&snapshot)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	if _, err := tx.exec(ctx, stmt.query, stmt.args...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *snapshotStore) LatestSnapshot(ctx context.Context, t eventsource.Transaction, id eventsource.AggregateID, schemaVersion int) (*eventsource.Snapshot, error) {
+	return s.SnapshotAt(ctx, t, id, 0, schemaVersion)
+}
+
+func (s *snapshotStore) SnapshotAt(ctx context.Context, t eventsource.Transaction, id eventsource.AggregateID, version eventsource.AggregateVersion, schemaVersion int) (*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.snapshotStore.SnapshotAt")
+	defer span.End()
+
+	tx, err := connFor(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return s.load(ctx, tx, id, version, schemaVersion)
+}
+
+func (s *snapshotStore) DeleteSnapshots(ctx context.Context, t eventsource.Transaction, id eventsource.AggregateID, versions ...eventsource.AggregateVersion) error {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.snapshotStore.DeleteSnapshots")
+	defer span.End()
+
+	tx, err := connFor(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("delete from %s ", s.tableName()))
+	b.WriteString("where aggregate_id = $1 ")
+
+	args := []any{
+		id.String(),
+	}
+
+	if len(versions) > 0 {
+		values := make([]string, 0, len(versions))
+		for _, v := range versions {
+			values = append(values, strconv.FormatInt(v.Int64(), 10))
+		}
+
+		args = append(args, tx.array(values))
+		b.WriteString("and aggregate_version = any($2::text[]::bigint[]) ")
+	}
+
+	if _, err := tx.exec(ctx, b.String(), args...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// load returns the latest snapshot of the aggregate at or before the version,
+// or at any version when it is 0, taken with the schema version, or with any
+// schema version when it is 0.
+func (s *snapshotStore) load(ctx context.Context, tx conn, id eventsource.AggregateID, version eventsource.AggregateVersion, schemaVersion int) (*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.snapshotStore.load")
+	defer span.End()
+
+	b := strings.Builder{}
+
+	b.WriteString("select aggregate_id, aggregate_type, aggregate_version, schema_version, taken_at, data ")
+	b.WriteString(fmt.Sprintf("from %s ", s.tableName()))
+	b.WriteString("where aggregate_id = $1 ")
+
+	args := []any{
+		id.String(),
+	}
+
+	if version != 0 {
+		args = append(args, version.Int64())
+		b.WriteString(fmt.Sprintf("and aggregate_version <= $%d ", len(args)))
+	}
+
+	if schemaVersion != 0 {
+		args = append(args, schemaVersion)
+		b.WriteString(fmt.Sprintf("and schema_version = $%d ", len(args)))
+	}
+
+	b.WriteString("order by aggregate_version desc ")
+	b.WriteString("limit 1; ")
+
+	snapshot := Snapshot{}
+	if err := tx.queryRow(ctx, b.String(), args...).Scan(
+		&snapshot.AggregateID,
+		&snapshot.AggregateType,
+		&snapshot.AggregateVersion,
+		&snapshot.SchemaVersion,
+		&snapshot.TakenAt,
+		&snapshot.Data,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, eventsource.ErrNoSnapshotFound
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return snapshot.ToSnapshot(), nil
+}
+
+// loadLatest returns the latest snapshot of each schema version of the
+// aggregates, by aggregate id and type.
+func (s *snapshotStore) loadLatest(ctx context.Context, tx conn, ids []string) (map[aggregateKey][]*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.snapshotStore.loadLatest")
+	defer span.End()
+
+	results := make(map[aggregateKey][]*eventsource.Snapshot, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString("select aggregate_id, aggregate_type, aggregate_version, schema_version, taken_at, data ")
+	b.WriteString("from (")
+	b.WriteString("select *, row_number() over (partition by aggregate_id, aggregate_type, schema_version order by aggregate_version desc) as rank ")
+	b.WriteString(fmt.Sprintf("from %s ", s.tableName()))
+	b.WriteString("where aggregate_id = any($1)")
+	b.WriteString(") as latest ")
+	b.WriteString("where rank = 1; ")
+
+	rows, err := tx.query(ctx, b.String(), tx.array(ids))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		snapshot := Snapshot{}
+		if err := rows.Scan(
+			&snapshot.AggregateID,
+			&snapshot.AggregateType,
+			&snapshot.AggregateVersion,
+			&snapshot.SchemaVersion,
+			&snapshot.TakenAt,
+			&snapshot.Data,
+		); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		ss := snapshot.ToSnapshot()
+		key := aggregateKey{ss.AggregateID, ss.AggregateType}
+		results[key] = append(results[key], ss)
+	}
+
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// loadInfo returns the version, the schema version and the time of the latest
+// snapshot of the aggregate, without its data.
+func (s *snapshotStore) loadInfo(ctx context.Context, tx conn, id eventsource.AggregateID) (*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.postgres.snapshotStore.loadInfo")
+	defer span.End()
+
+	b := strings.Builder{}
+
+	b.WriteString("select aggregate_version, schema_version, taken_at ")
+	b.WriteString(fmt.Sprintf("from %s ", s.tableName()))
+	b.WriteString("where aggregate_id = $1 ")
+	b.WriteString("order by aggregate_version desc ")
+	b.WriteString("limit 1; ")
+
+	snapshot := Snapshot{}
+	if err := tx.queryRow(ctx, b.String(), id.String()).Scan(&snapshot.AggregateVersion, &snapshot.SchemaVersion, &snapshot.TakenAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, eventsource.ErrNoSnapshotFound
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	snapshot.AggregateID = sql.NullString{String: id.String(), Valid: true}
+
+	return snapshot.ToSnapshot(), nil
+}
+
+// purge deletes the snapshots of the aggregate type taken with another schema
+// version than schemaVersion.
+func (s *snapshotStore) purge(ctx context.Context, tx conn, aggregateType eventsource.AggregateType, schemaVersion int) (int64, error) {
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("delete from %s ", s.tableName()))
+	b.WriteString("where aggregate_type = $1 ")
+	b.WriteString("and schema_version <> $2; ")
+
+	return tx.exec(ctx, b.String(), aggregateType.String(), schemaVersion)
+}
+
+func (s *snapshotStore) insertStatement(ss ...*eventsource.Snapshot) (statement, error) {
+	insertBuilder := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).
+		Insert(s.tableName()).
+		Columns(
+			"aggregate_id",
+			"aggregate_type",
+			"aggregate_version",
+			"schema_version",
+			"taken_at",
+			"registered_at",
+			"data",
+		)
+
+	for _, snap := range ss {
+		sqlSnap := FromSnapshot(*snap)
+		insertBuilder = insertBuilder.Values(
+			sqlSnap.AggregateID,
+			sqlSnap.AggregateType,
+			sqlSnap.AggregateVersion,
+			sqlSnap.SchemaVersion,
+			sqlSnap.TakenAt,
+			time.Now().UTC(),
+			sqlSnap.Data,
+		)
+	}
+
+	return insertStatement(insertBuilder)
+}
+
+func (s *snapshotStore) tableName() string {
+	return s.options.computeTableName(s.options.snapshotStorageParams.tableName)
+}
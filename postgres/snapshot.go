@@ -21,6 +21,10 @@ func FromSnapshot(s eventsource.Snapshot) *Snapshot {
 			Int64: s.AggregateVersion.Int64(),
 			Valid: !s.AggregateVersion.IsZero(),
 		},
+		SchemaVersion: sql.NullInt64{
+			Int64: int64(eventsource.NormalizeSnapshotVersion(s.SchemaVersion)),
+			Valid: true,
+		},
 		TakenAt: sql.NullTime{
 			Time:  s.TakenAt,
 			Valid: !s.TakenAt.IsZero(),
@@ -33,6 +37,7 @@ type Snapshot struct {
 	AggregateID      sql.NullString
 	AggregateType    sql.NullString
 	AggregateVersion sql.NullInt64
+	SchemaVersion    sql.NullInt64
 	TakenAt          sql.NullTime
 	Data             json.RawMessage
 }
@@ -42,6 +47,7 @@ func (s *Snapshot) ToSnapshot() *eventsource.Snapshot {
 		AggregateID:      eventsource.AggregateID(s.AggregateID.String),
 		AggregateType:    eventsource.AggregateType(s.AggregateType.String),
 		AggregateVersion: eventsource.AggregateVersion(s.AggregateVersion.Int64),
+		SchemaVersion:    int(s.SchemaVersion.Int64),
 		TakenAt:          s.TakenAt.Time,
 		Data:             s.Data,
 	}
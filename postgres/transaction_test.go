@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/thefabric-io/eventsource"
+)
+
+var (
+	_ DBTX = (*sql.Tx)(nil)
+	_ DBTX = (*sqlx.Tx)(nil)
+)
+
+type unsupportedTx struct{}
+
+func (unsupportedTx) Commit() error   { return nil }
+func (unsupportedTx) Rollback() error { return nil }
+
+type sqlTx struct {
+	DBTX
+	unsupportedTx
+}
+
+type wrappedTx struct {
+	unsupportedTx
+	tx eventsource.Transaction
+}
+
+func (w wrappedTx) Unwrap() eventsource.Transaction {
+	return w.tx
+}
+
+func Test_dbtx(t *testing.T) {
+	inner := sqlTx{}
+
+	tests := []struct {
+		name    string
+		tx      eventsource.Transaction
+		wantErr error
+	}{
+		{name: "nil transaction", tx: nil, wantErr: eventsource.ErrTransactionIsRequired},
+		{name: "unsupported transaction", tx: unsupportedTx{}, wantErr: eventsource.ErrUnsupportedTransaction},
+		{name: "sql transaction", tx: inner},
+		{name: "wrapped sql transaction", tx: wrappedTx{tx: inner}},
+		{name: "wrapped unsupported transaction", tx: wrappedTx{tx: unsupportedTx{}}, wantErr: eventsource.ErrUnsupportedTransaction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dbtx(tt.tx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("dbtx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && got == nil {
+				t.Errorf("dbtx() = nil, want a DBTX")
+			}
+		})
+	}
+}
+
+func Test_connFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		tx      eventsource.Transaction
+		want    conn
+		wantErr error
+	}{
+		{name: "sql transaction", tx: sqlTx{}, want: &sqlConn{}},
+		{name: "pgx transaction", tx: NewPgxTransaction(context.Background(), nil), want: &pgxConn{}},
+		{name: "wrapped pgx transaction", tx: wrappedTx{tx: NewPgxTransaction(context.Background(), nil)}, want: &pgxConn{}},
+		{name: "unsupported transaction", tx: unsupportedTx{}, wantErr: eventsource.ErrUnsupportedTransaction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := connFor(tt.tx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("connFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+				t.Errorf("connFor() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
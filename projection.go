@@ -0,0 +1,62 @@
+package eventsource
+
+import (
+	"context"
+	"fmt"
+)
+
+// Projection maintains read models from the events of the event store. It
+// writes through the transaction it is given so that its writes are
+// committed along with the events when run inline by an event store, or
+// along with its checkpoint when run from a subscription.
+type Projection interface {
+	// Name identifies the projection and is used as the name of its
+	// subscription.
+	Name() string
+	// Handles returns the types of the events the projection handles.
+	Handles() []EventType
+	Project(ctx context.Context, tx Transaction, e EventReadModel) error
+}
+
+// Project hands the events to the projections handling them, in order.
+func Project(ctx context.Context, tx Transaction, ee []EventReadModel, projections ...Projection) error {
+	for _, p := range projections {
+		handler := ProjectionHandler(p)
+
+		for _, e := range ee {
+			if err := handler.Handle(ctx, tx, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ProjectionHandler returns an EventHandler handing the events the projection
+// handles to it and skipping the others.
+func ProjectionHandler(p Projection) EventHandler {
+	handles := make(map[EventType]struct{}, len(p.Handles()))
+	for _, t := range p.Handles() {
+		handles[t] = struct{}{}
+	}
+
+	return EventHandlerFunc(func(ctx context.Context, tx Transaction, e EventReadModel) error {
+		if _, exists := handles[e.Type]; !exists {
+			return nil
+		}
+
+		if err := p.Project(ctx, tx, e); err != nil {
+			return fmt.Errorf("projection '%s' could not project event '%s': %w", p.Name(), e.ID, err)
+		}
+
+		return nil
+	})
+}
+
+// RunProjection runs the projection asynchronously from a subscription named
+// after it. Only the event types the projection handles are read, and its
+// checkpoint is saved in the transaction of its writes.
+func (s *Subscriber) RunProjection(ctx context.Context, p Projection, fromCheckpoint int64) error {
+	return s.subscribe(ctx, p.Name(), fromCheckpoint, ProjectionHandler(p), WithEventTypes(p.Handles()...))
+}
@@ -0,0 +1,114 @@
+package eventsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrUnknownEventType = errors.New("unknown event type")
+	ErrNoEventsParser   = errors.New("no events parser")
+)
+
+// EventsParser is implemented by aggregates decoding their own events. It is
+// only used by the event stores when no Registry is configured.
+type EventsParser interface {
+	ParseEvents(context.Context, ...EventReadModel) []Event
+}
+
+// ParseEvents decodes the events with the registry when there is one, and
+// falls back on the aggregate's own EventsParser implementation otherwise.
+func ParseEvents(ctx context.Context, r *Registry, a Aggregate, ee ...EventReadModel) ([]Event, error) {
+	if r != nil {
+		return r.Parse(ctx, ee...)
+	}
+
+	if p, implements := a.(EventsParser); implements {
+		return p.ParseEvents(ctx, ee...), nil
+	}
+
+	return nil, fmt.Errorf("%w: aggregate '%s' does not implement ParseEvents and no registry is configured", ErrNoEventsParser, a.Type())
+}
+
+// RegistryProvider is implemented by the event stores decoding events with a
+// Registry, so that the stores decorating them decode events the same way.
+type RegistryProvider interface {
+	Registry() *Registry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[EventType]func(*BaseEvent) Event),
+	}
+}
+
+// Registry decodes stored events into their concrete types.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[EventType]func(*BaseEvent) Event
+}
+
+// Register associates the event type with the factory building an empty
+// event around its base. Registering the same type twice replaces the
+// previous factory.
+//
+//	eventsource.Register(r, "organization_created", func(b *eventsource.BaseEvent) *OrganizationCreated {
+//		return &OrganizationCreated{BaseEvent: b}
+//	})
+func Register[E Event](r *Registry, t EventType, factory func(*BaseEvent) E) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factories[t] = func(b *BaseEvent) Event {
+		return factory(b)
+	}
+}
+
+// IsRegistered reports whether a factory is registered for the event type.
+func (r *Registry) IsRegistered(t EventType) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.factories[t]
+
+	return exists
+}
+
+// Parse decodes the events in order. It fails on the first event whose type is
+// not registered or whose data cannot be decoded.
+func (r *Registry) Parse(ctx context.Context, ee ...EventReadModel) ([]Event, error) {
+	results := make([]Event, 0, len(ee))
+
+	for i := range ee {
+		e, err := r.ParseEvent(ctx, ee[i])
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, e)
+	}
+
+	return results, nil
+}
+
+func (r *Registry) ParseEvent(_ context.Context, e EventReadModel) (Event, error) {
+	r.mu.RLock()
+	factory, exists := r.factories[e.Type]
+	r.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s' (event '%s')", ErrUnknownEventType, e.Type, e.ID)
+	}
+
+	event := factory(e.InitBaseEvent())
+
+	if len(e.Data) > 0 {
+		if err := UnmarshalES(e.Data, event); err != nil {
+			return nil, fmt.Errorf("could not decode event '%s' of type '%s': %w", e.ID, e.Type, err)
+		}
+	}
+
+	return event, nil
+}
@@ -0,0 +1,73 @@
+package eventsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type renamed struct {
+	*BaseEvent
+	Name string `es:"name"`
+}
+
+func (e *renamed) Type() EventType {
+	return "renamed"
+}
+
+func (e *renamed) ApplyTo(context.Context, Aggregate) {}
+
+func TestRegistry_Parse(t *testing.T) {
+	r := NewRegistry()
+	Register(r, "renamed", func(b *BaseEvent) *renamed {
+		return &renamed{BaseEvent: b}
+	})
+
+	tests := []struct {
+		name    string
+		events  []EventReadModel
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "registered events",
+			events: []EventReadModel{
+				{ID: "evt_1", Type: "renamed", AggregateID: "agg_1", AggregateVersion: 1, Data: json.RawMessage(`{"name":"first"}`)},
+				{ID: "evt_2", Type: "renamed", AggregateID: "agg_1", AggregateVersion: 2, Data: json.RawMessage(`{"name":"second"}`)},
+			},
+			want: []string{"first", "second"},
+		},
+		{
+			name: "unknown event type",
+			events: []EventReadModel{
+				{ID: "evt_1", Type: "renamed", Data: json.RawMessage(`{"name":"first"}`)},
+				{ID: "evt_2", Type: "deleted"},
+			},
+			wantErr: ErrUnknownEventType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Parse(context.Background(), tt.events...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Parse() returned %d events, want %d", len(got), len(tt.want))
+			}
+
+			for i, e := range got {
+				event, ok := e.(*renamed)
+				if !ok {
+					t.Fatalf("Parse()[%d] type = %T, want *renamed", i, e)
+				}
+
+				if event.Name != tt.want[i] || event.ID() != tt.events[i].ID || event.AggregateVersion() != tt.events[i].AggregateVersion {
+					t.Errorf("Parse()[%d] = %+v, want name %q from %+v", i, event, tt.want[i], tt.events[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package eventsource
+
+import (
+	"sort"
+	"time"
+)
+
+// RetentionPolicy decides which snapshots of an aggregate are kept. A snapshot
+// is kept when it is among the KeepLast newest snapshots of its aggregate, or
+// when it is newer than MaxAge. The newest snapshot of an aggregate, the only
+// one Load reads, is always kept, so that the zero policy keeps only it.
+type RetentionPolicy struct {
+	KeepLast int
+	MaxAge   time.Duration
+}
+
+// KeepLast returns a policy keeping the n newest snapshots of each aggregate.
+func KeepLast(n int) RetentionPolicy {
+	return RetentionPolicy{KeepLast: n}
+}
+
+// KeepNewerThan returns a policy keeping the snapshots taken within d, and the
+// newest snapshot of each aggregate.
+func KeepNewerThan(d time.Duration) RetentionPolicy {
+	return RetentionPolicy{MaxAge: d}
+}
+
+// KeptNewest returns the number of newest snapshots of each aggregate kept
+// regardless of their age.
+func (p RetentionPolicy) KeptNewest() int {
+	if p.KeepLast < 1 {
+		return 1
+	}
+
+	return p.KeepLast
+}
+
+// Cutoff returns the time before which the snapshots beyond the KeptNewest
+// ones are pruned. It is the zero time when the policy has no MaxAge, in which
+// case they are pruned whatever their age.
+func (p RetentionPolicy) Cutoff(now time.Time) time.Time {
+	if p.MaxAge <= 0 {
+		return time.Time{}
+	}
+
+	return now.Add(-p.MaxAge)
+}
+
+// Prunable returns the snapshots of a single aggregate that the policy does
+// not keep.
+func (p RetentionPolicy) Prunable(ss []Snapshot, now time.Time) []Snapshot {
+	sorted := make([]Snapshot, len(ss))
+	copy(sorted, ss)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].AggregateVersion > sorted[j].AggregateVersion
+	})
+
+	cutoff := p.Cutoff(now)
+
+	results := make([]Snapshot, 0)
+	for i, s := range sorted {
+		if i < p.KeptNewest() {
+			continue
+		}
+
+		if !cutoff.IsZero() && !s.TakenAt.Before(cutoff) {
+			continue
+		}
+
+		results = append(results, s)
+	}
+
+	return results
+}
+
+// PruneOption configures the pruning of snapshots.
+type PruneOption func(*PruneOptions)
+
+// WithPruneChunkSize sets the number of aggregates whose snapshots are pruned
+// in each transaction.
+func WithPruneChunkSize(size int) PruneOption {
+	return func(o *PruneOptions) {
+		if size > 0 {
+			o.ChunkSize = size
+		}
+	}
+}
+
+func NewPruneOptions(opts ...PruneOption) *PruneOptions {
+	const (
+		defaultChunkSize = 500
+	)
+
+	result := &PruneOptions{
+		ChunkSize: defaultChunkSize,
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+type PruneOptions struct {
+	ChunkSize int
+}
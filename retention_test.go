@@ -0,0 +1,64 @@
+package eventsource
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicy_Prunable(t *testing.T) {
+	now := time.Now()
+
+	ss := []Snapshot{
+		{AggregateVersion: 10, TakenAt: now.Add(-4 * time.Hour)},
+		{AggregateVersion: 40, TakenAt: now.Add(-time.Minute)},
+		{AggregateVersion: 20, TakenAt: now.Add(-3 * time.Hour)},
+		{AggregateVersion: 30, TakenAt: now.Add(-30 * time.Minute)},
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []AggregateVersion
+	}{
+		{
+			name:   "zero policy keeps the newest",
+			policy: RetentionPolicy{},
+			want:   []AggregateVersion{30, 20, 10},
+		},
+		{
+			name:   "keep last",
+			policy: KeepLast(2),
+			want:   []AggregateVersion{20, 10},
+		},
+		{
+			name:   "keep newer than",
+			policy: KeepNewerThan(time.Hour),
+			want:   []AggregateVersion{20, 10},
+		},
+		{
+			name:   "keep newer than keeps the newest",
+			policy: KeepNewerThan(time.Second),
+			want:   []AggregateVersion{30, 20, 10},
+		},
+		{
+			name:   "keep last or newer than",
+			policy: RetentionPolicy{KeepLast: 3, MaxAge: time.Hour},
+			want:   []AggregateVersion{10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Prunable(ss, now)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Prunable() returned %d snapshots, want %v", len(got), tt.want)
+			}
+
+			for i, s := range got {
+				if s.AggregateVersion != tt.want[i] {
+					t.Errorf("Prunable()[%d] version = %d, want %d", i, s.AggregateVersion, tt.want[i])
+				}
+			}
+		})
+	}
+}
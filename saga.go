@@ -0,0 +1,202 @@
+package eventsource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Saga is a process manager coordinating aggregates: an event-sourced
+// aggregate recording the progress of a workflow, which reacts to the events of
+// the aggregates it coordinates by raising its own events and emitting
+// commands.
+type Saga interface {
+	Aggregate
+	// HandleEvent reacts to an event correlated to the saga, raising the
+	// events of its progress with Raise, and returns the commands to
+	// dispatch.
+	HandleEvent(ctx context.Context, e EventReadModel) ([]Command, error)
+}
+
+// CorrelationFunc returns the correlation key of the saga an event belongs to,
+// or false when the event does not belong to any saga.
+type CorrelationFunc func(e EventReadModel) (string, bool)
+
+// CorrelateByAggregateID correlates the events by the id of their aggregate.
+func CorrelateByAggregateID() CorrelationFunc {
+	return func(e EventReadModel) (string, bool) {
+		return e.AggregateID.String(), !e.AggregateID.IsZero()
+	}
+}
+
+// CorrelateByMetadata correlates the events by the value of a key of their
+// metadata.
+func CorrelateByMetadata(key string) CorrelationFunc {
+	return func(e EventReadModel) (string, bool) {
+		v, ok := e.Metadata[key]
+		if !ok || v == nil {
+			return "", false
+		}
+
+		return correlationKey(v)
+	}
+}
+
+// CorrelateByData correlates the events by the value of a field of their data,
+// given as a dot separated path such as "order.id".
+func CorrelateByData(path string) CorrelationFunc {
+	return func(e EventReadModel) (string, bool) {
+		decoder := json.NewDecoder(bytes.NewReader(e.Data))
+		decoder.UseNumber()
+
+		var v any
+		if err := decoder.Decode(&v); err != nil {
+			return "", false
+		}
+
+		for _, field := range strings.Split(path, ".") {
+			object, ok := v.(map[string]any)
+			if !ok {
+				return "", false
+			}
+
+			if v, ok = object[field]; !ok || v == nil {
+				return "", false
+			}
+		}
+
+		return correlationKey(v)
+	}
+}
+
+func correlationKey(v any) (string, bool) {
+	switch v := v.(type) {
+	case map[string]any, []any:
+		return "", false
+	default:
+		key := fmt.Sprint(v)
+
+		return key, key != ""
+	}
+}
+
+// NewSagaManager returns a SagaManager saving the sagas in store and
+// dispatching their commands with dispatcher.
+func NewSagaManager(store EventStore, dispatcher *Dispatcher) *SagaManager {
+	return &SagaManager{
+		store:      store,
+		dispatcher: dispatcher,
+		sagas:      make(map[EventType][]sagaDefinition),
+	}
+}
+
+// SagaManager is the EventHandler running the sagas. It is driven by a
+// subscription, e.g. with Subscriber.Subscribe: the sagas, the commands they
+// emit and the checkpoint of the subscription are then saved in the same
+// transaction, so that the events are handled exactly once.
+type SagaManager struct {
+	store      EventStore
+	dispatcher *Dispatcher
+
+	mu    sync.RWMutex
+	sagas map[EventType][]sagaDefinition
+}
+
+type sagaDefinition struct {
+	// saga returns an empty saga for the correlation key, to be loaded.
+	saga      func(key string) Saga
+	correlate CorrelationFunc
+}
+
+// SagaID returns the id of the saga of the type for the correlation key. Events
+// are unique by aggregate id and version whatever the type of their aggregate:
+// sagas correlated by the id of the aggregate they coordinate must not take it
+// as their own id, or they would share its stream.
+func SagaID(sagaType AggregateType, key string) string {
+	return sagaType.String() + ":" + key
+}
+
+// RegisterSaga makes the manager hand the events of the types to the saga built
+// by factory for their correlation key. The saga is loaded before handling the
+// event, or given at version 0 when it does not exist yet. The factory derives
+// the id of the saga from the key with SagaID.
+//
+//	func NewFulfillment(key string) *Fulfillment {
+//		return &Fulfillment{BaseAggregate: eventsource.InitAggregate(eventsource.SagaID("fulfillment", key), "fulfillment")}
+//	}
+//
+//	eventsource.RegisterSaga(m, NewFulfillment, eventsource.CorrelateByData("order_id"),
+//		"order_placed", "payment_captured", "shipment_dispatched")
+func RegisterSaga[S Saga](m *SagaManager, factory func(key string) S, correlate CorrelationFunc, eventTypes ...EventType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	definition := sagaDefinition{
+		saga: func(key string) Saga {
+			return factory(key)
+		},
+		correlate: correlate,
+	}
+
+	for _, t := range eventTypes {
+		m.sagas[t] = append(m.sagas[t], definition)
+	}
+}
+
+// Handle hands the event to the sagas registered for its type and correlated
+// to it, saves their changes and dispatches their commands within tx.
+func (m *SagaManager) Handle(ctx context.Context, tx Transaction, e EventReadModel) error {
+	m.mu.RLock()
+	definitions := m.sagas[e.Type]
+	m.mu.RUnlock()
+
+	for _, definition := range definitions {
+		key, ok := definition.correlate(e)
+		if !ok {
+			continue
+		}
+
+		if err := m.handle(ctx, tx, definition.saga(key), e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *SagaManager) handle(ctx context.Context, tx Transaction, saga Saga, e EventReadModel) error {
+	loaded, err := m.store.Load(ctx, tx, saga)
+	if errors.Is(err, ErrAggregateDoNotExist) {
+		loaded = saga
+	} else if err != nil {
+		return err
+	}
+
+	saga, ok := loaded.(Saga)
+	if !ok {
+		return fmt.Errorf("saga '%s' of type '%s' loaded as a %T", loaded.ID(), loaded.Type(), loaded)
+	}
+
+	commands, err := saga.HandleEvent(ctx, e)
+	if err != nil {
+		return fmt.Errorf("saga '%s' of type '%s' could not handle event '%s': %w", saga.ID(), saga.Type(), e.ID, err)
+	}
+
+	if len(saga.Changes()) > 0 {
+		if err := m.store.Save(ctx, tx, saga); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range commands {
+		if err := m.dispatcher.DispatchTx(ctx, tx, c); err != nil {
+			return fmt.Errorf("saga '%s' of type '%s' could not dispatch command '%s': %w", saga.ID(), saga.Type(), c.Type(), err)
+		}
+	}
+
+	return nil
+}
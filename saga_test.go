@@ -0,0 +1,45 @@
+package eventsource
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCorrelationFunc(t *testing.T) {
+	e := EventReadModel{
+		AggregateID: "order_1",
+		Metadata:    map[string]interface{}{"workflow": "wf_1", "empty": ""},
+		Data:        json.RawMessage(`{"order":{"id":"order_1","number":12345678901},"lines":[1,2]}`),
+	}
+
+	tests := []struct {
+		name      string
+		correlate CorrelationFunc
+		want      string
+		wantOK    bool
+	}{
+		{name: "aggregate id", correlate: CorrelateByAggregateID(), want: "order_1", wantOK: true},
+		{name: "metadata", correlate: CorrelateByMetadata("workflow"), want: "wf_1", wantOK: true},
+		{name: "missing metadata", correlate: CorrelateByMetadata("tenant")},
+		{name: "empty metadata", correlate: CorrelateByMetadata("empty")},
+		{name: "nested data", correlate: CorrelateByData("order.id"), want: "order_1", wantOK: true},
+		{name: "number data", correlate: CorrelateByData("order.number"), want: "12345678901", wantOK: true},
+		{name: "missing data", correlate: CorrelateByData("order.customer")},
+		{name: "object data", correlate: CorrelateByData("order")},
+		{name: "array data", correlate: CorrelateByData("lines")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.correlate(e)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("correlate() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSagaID(t *testing.T) {
+	if got := SagaID("fulfillment", "order_1"); got != "fulfillment:order_1" {
+		t.Errorf("SagaID() = %q, want %q", got, "fulfillment:order_1")
+	}
+}
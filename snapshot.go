@@ -1,19 +1,78 @@
 package eventsource
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
 	"time"
 )
 
-func FromSnapshot(snapshot *Snapshot, a Aggregate) {
-	if snapshot != nil {
-		if err := UnmarshalES(snapshot.Data, a); err != nil {
-			log.Printf("could not unserialize snapshot of aggregate '%s'", snapshot.AggregateID)
-			return
+var (
+	ErrIncompatibleSnapshot      = errors.New("incompatible snapshot schema version")
+	ErrSnapshotPurgeNotSupported = errors.New("event store does not support purging snapshots")
+)
+
+// SnapshotVersioner is implemented by aggregates declaring the schema version
+// of their snapshots. The version must be incremented whenever the serialized
+// fields of the aggregate change, so that snapshots of previous versions are
+// ignored rather than restored into the wrong state.
+type SnapshotVersioner interface {
+	SnapshotVersion() int
+}
+
+// DefaultSnapshotVersion is the schema version of the snapshots of the
+// aggregates that do not declare one.
+const DefaultSnapshotVersion = 1
+
+// NormalizeSnapshotVersion returns the schema version, or
+// DefaultSnapshotVersion when it is 0, so that snapshots built without one
+// are stored and compared at the default version.
+func NormalizeSnapshotVersion(v int) int {
+	if v == 0 {
+		return DefaultSnapshotVersion
+	}
+
+	return v
+}
+
+// SnapshotVersionOf returns the snapshot schema version of the aggregate,
+// which defaults to DefaultSnapshotVersion.
+func SnapshotVersionOf(a Aggregate) int {
+	if v, ok := a.(SnapshotVersioner); ok {
+		return NormalizeSnapshotVersion(v.SnapshotVersion())
+	}
+
+	return DefaultSnapshotVersion
+}
+
+// FromSnapshot restores the aggregate from the snapshot, if any, and returns a
+// *SnapshotError when the snapshot cannot be decoded.
+func FromSnapshot(snapshot *Snapshot, a Aggregate) error {
+	if snapshot == nil {
+		return nil
+	}
+
+	if v := NormalizeSnapshotVersion(snapshot.SchemaVersion); v != SnapshotVersionOf(a) {
+		return &SnapshotError{
+			AggregateID:      snapshot.AggregateID,
+			AggregateType:    snapshot.AggregateType,
+			AggregateVersion: snapshot.AggregateVersion,
+			Err:              fmt.Errorf("%w: %d, want %d", ErrIncompatibleSnapshot, v, SnapshotVersionOf(a)),
 		}
+	}
 
-		a.SetVersion(snapshot.AggregateVersion)
+	if err := UnmarshalES(snapshot.Data, a); err != nil {
+		return &SnapshotError{
+			AggregateID:      snapshot.AggregateID,
+			AggregateType:    snapshot.AggregateType,
+			AggregateVersion: snapshot.AggregateVersion,
+			Err:              err,
+		}
 	}
+
+	a.SetVersion(snapshot.AggregateVersion)
+
+	return nil
 }
 
 func NewSnapshot(a Aggregate) (*Snapshot, error) {
@@ -22,19 +81,74 @@ func NewSnapshot(a Aggregate) (*Snapshot, error) {
 		return nil, err
 	}
 
-	return &Snapshot{
+	snapshot := &Snapshot{
 		AggregateID:      a.ID(),
 		AggregateType:    a.Type(),
 		AggregateVersion: a.Version(),
+		SchemaVersion:    SnapshotVersionOf(a),
 		TakenAt:          time.Now(),
 		Data:             b,
-	}, nil
+	}
+
+	if changes := a.Changes(); len(changes) > 0 && changes[len(changes)-1].AggregateVersion() == a.Version() {
+		snapshot.EventID = changes[len(changes)-1].ID()
+	}
+
+	return snapshot, nil
 }
 
 type Snapshot struct {
 	AggregateID      AggregateID
 	AggregateType    AggregateType
 	AggregateVersion AggregateVersion
-	TakenAt          time.Time
-	Data             []byte
+	// SchemaVersion is the SnapshotVersion of the aggregate when the
+	// snapshot was taken.
+	SchemaVersion int
+	// EventID is the id of the event bringing the aggregate to
+	// AggregateVersion when the snapshot was taken on Save, or empty.
+	EventID EventID
+	TakenAt time.Time
+	Data    []byte
+}
+
+// SnapshotStore stores the snapshots of the aggregates. Event stores keep
+// snapshots in their own database by default and delegate to a SnapshotStore
+// when configured with one, e.g. to keep large snapshots out of the primary
+// database. Implementations that do not take part in the transaction may hold
+// snapshots of versions whose events were rolled back: they must keep the
+// EventID of the snapshots, which event stores check against the event stored
+// at their version to skip such orphans.
+type SnapshotStore interface {
+	SaveSnapshot(ctx context.Context, tx Transaction, s Snapshot) error
+	// LatestSnapshot returns the latest snapshot of the aggregate taken with
+	// the schema version, or with any schema version when it is 0, or
+	// ErrNoSnapshotFound.
+	LatestSnapshot(ctx context.Context, tx Transaction, id AggregateID, schemaVersion int) (*Snapshot, error)
+	// SnapshotAt returns the latest snapshot of the aggregate at or before
+	// the version, or at any version when it is 0, taken with the schema
+	// version, or with any schema version when it is 0, or
+	// ErrNoSnapshotFound.
+	SnapshotAt(ctx context.Context, tx Transaction, id AggregateID, version AggregateVersion, schemaVersion int) (*Snapshot, error)
+	// DeleteSnapshots deletes the snapshots of the aggregate at the versions,
+	// or all of them when no version is given.
+	DeleteSnapshots(ctx context.Context, tx Transaction, id AggregateID, versions ...AggregateVersion) error
+}
+
+// SnapshotPurger is implemented by the event stores able to delete the
+// snapshots of outdated schema versions.
+type SnapshotPurger interface {
+	// PurgeSnapshots deletes the snapshots of the aggregate type whose
+	// schema version differs from schemaVersion and returns their number.
+	PurgeSnapshots(ctx context.Context, tx Transaction, aggregateType AggregateType, schemaVersion int) (int64, error)
+}
+
+// PurgeOutdatedSnapshots deletes the snapshots of the type of the aggregate
+// that were taken with another schema version than its current one.
+func PurgeOutdatedSnapshots(ctx context.Context, store EventStore, tx Transaction, a Aggregate) (int64, error) {
+	purger, ok := store.(SnapshotPurger)
+	if !ok {
+		return 0, fmt.Errorf("%w: %T", ErrSnapshotPurgeNotSupported, store)
+	}
+
+	return purger.PurgeSnapshots(ctx, tx, a.Type(), SnapshotVersionOf(a))
 }
@@ -0,0 +1,203 @@
+package eventsource
+
+import (
+	"context"
+	"time"
+)
+
+// SnapshotContext describes a version of an aggregate reached by a saved
+// event, for a SnapshotStrategy to decide whether it is snapshotted.
+type SnapshotContext struct {
+	Aggregate Aggregate
+	// Event is the saved event bringing the aggregate to Version.
+	Event   Event
+	Version AggregateVersion
+	// LastSnapshotVersion and LastSnapshotTakenAt describe the latest
+	// stored snapshot of the aggregate. They are zero when the aggregate has
+	// no snapshot compatible with its current schema version.
+	LastSnapshotVersion AggregateVersion
+	LastSnapshotTakenAt time.Time
+	// StaleSnapshot reports that the latest stored snapshot was taken with
+	// another schema version, so that Load replays the full stream.
+	StaleSnapshot bool
+	Now           time.Time
+}
+
+// EventsSinceLastSnapshot returns the number of events Load replays on top of
+// the latest snapshot when the aggregate is at Version.
+func (c SnapshotContext) EventsSinceLastSnapshot() int {
+	return int(c.Version - c.LastSnapshotVersion)
+}
+
+// SnapshotStrategy decides on Save which versions of an aggregate are
+// snapshotted.
+type SnapshotStrategy interface {
+	ShouldSnapshot(ctx context.Context, c SnapshotContext) bool
+}
+
+type SnapshotStrategyFunc func(ctx context.Context, c SnapshotContext) bool
+
+func (f SnapshotStrategyFunc) ShouldSnapshot(ctx context.Context, c SnapshotContext) bool {
+	return f(ctx, c)
+}
+
+// LastSnapshotReader is implemented by strategies telling whether they read
+// the latest stored snapshot of the aggregate, i.e. LastSnapshotVersion,
+// LastSnapshotTakenAt and StaleSnapshot. The stores only fetch it for the
+// strategies reading it.
+type LastSnapshotReader interface {
+	ReadsLastSnapshot() bool
+}
+
+// ReadsLastSnapshot reports whether the stores fetch the latest stored
+// snapshot of the aggregate before asking the strategy. Strategies which do
+// not implement LastSnapshotReader are assumed to read it.
+func ReadsLastSnapshot(strategy SnapshotStrategy) bool {
+	if strategy == nil {
+		return false
+	}
+
+	if r, ok := strategy.(LastSnapshotReader); ok {
+		return r.ReadsLastSnapshot()
+	}
+
+	return true
+}
+
+// versionStrategy is a strategy deciding from the versions and events of the
+// aggregate alone.
+type versionStrategy SnapshotStrategyFunc
+
+func (f versionStrategy) ShouldSnapshot(ctx context.Context, c SnapshotContext) bool {
+	return f(ctx, c)
+}
+
+func (f versionStrategy) ReadsLastSnapshot() bool {
+	return false
+}
+
+// SnapshotStrategist is implemented by aggregates defining their own snapshot
+// strategy. It is used when Save is not given WithSnapshotStrategy.
+type SnapshotStrategist interface {
+	SnapshotStrategy() SnapshotStrategy
+}
+
+// NeverSnapshot never snapshots.
+func NeverSnapshot() SnapshotStrategy {
+	return versionStrategy(func(context.Context, SnapshotContext) bool {
+		return false
+	})
+}
+
+// EveryNEvents snapshots the versions multiple of n. It is the default
+// strategy, with the frequency given to WithSnapshot.
+func EveryNEvents(n int) SnapshotStrategy {
+	return versionStrategy(func(_ context.Context, c SnapshotContext) bool {
+		return n > 0 && int(c.Version)%n == 0
+	})
+}
+
+// AfterDuration snapshots when the latest snapshot is older than d, or when
+// there is none.
+func AfterDuration(d time.Duration) SnapshotStrategy {
+	return SnapshotStrategyFunc(func(_ context.Context, c SnapshotContext) bool {
+		return c.LastSnapshotTakenAt.IsZero() || c.Now.Sub(c.LastSnapshotTakenAt) >= d
+	})
+}
+
+// OnEventTypes snapshots the versions reached by events of the given types,
+// e.g. after an order is closed.
+func OnEventTypes(types ...EventType) SnapshotStrategy {
+	set := make(map[EventType]struct{}, len(types))
+	for _, t := range types {
+		set[t] = struct{}{}
+	}
+
+	return versionStrategy(func(_ context.Context, c SnapshotContext) bool {
+		_, ok := set[c.Event.Type()]
+
+		return ok
+	})
+}
+
+// OnStaleSnapshot snapshots when the latest snapshot of the aggregate was
+// taken with another schema version, re-snapshotting aggregates after an
+// upgrade of their SnapshotVersion.
+func OnStaleSnapshot() SnapshotStrategy {
+	return SnapshotStrategyFunc(func(_ context.Context, c SnapshotContext) bool {
+		return c.StaleSnapshot
+	})
+}
+
+// ReplayCost snapshots when loading the aggregate would replay at least
+// maxEvents events on top of its latest snapshot.
+func ReplayCost(maxEvents int) SnapshotStrategy {
+	return SnapshotStrategyFunc(func(_ context.Context, c SnapshotContext) bool {
+		return maxEvents > 0 && c.EventsSinceLastSnapshot() >= maxEvents
+	})
+}
+
+// AnyOf snapshots when any of the strategies does.
+func AnyOf(strategies ...SnapshotStrategy) SnapshotStrategy {
+	return anyOf(strategies)
+}
+
+type anyOf []SnapshotStrategy
+
+func (a anyOf) ShouldSnapshot(ctx context.Context, c SnapshotContext) bool {
+	for _, s := range a {
+		if s.ShouldSnapshot(ctx, c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a anyOf) ReadsLastSnapshot() bool {
+	for _, s := range a {
+		if ReadsLastSnapshot(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TakeSnapshot returns a snapshot of the current state of the aggregate when
+// the strategy selects any of the versions reached by its changes, or nil.
+// The aggregate is serialized at most once, at the end of the changes, which
+// makes the snapshot at least as recent as the versions selected. last is the
+// latest stored snapshot of the aggregate, whatever its schema version, or
+// nil. It is only needed when ReadsLastSnapshot reports that the strategy
+// reads it.
+func TakeSnapshot(ctx context.Context, strategy SnapshotStrategy, a Aggregate, last *Snapshot) (*Snapshot, error) {
+	if strategy == nil {
+		return nil, nil
+	}
+
+	c := SnapshotContext{
+		Aggregate: a,
+		Now:       time.Now(),
+	}
+
+	switch {
+	case last == nil:
+	case last.SchemaVersion != SnapshotVersionOf(a):
+		c.StaleSnapshot = true
+	default:
+		c.LastSnapshotVersion = last.AggregateVersion
+		c.LastSnapshotTakenAt = last.TakenAt
+	}
+
+	for _, e := range a.Changes() {
+		c.Event = e
+		c.Version = e.AggregateVersion()
+
+		if strategy.ShouldSnapshot(ctx, c) {
+			return NewSnapshot(a)
+		}
+	}
+
+	return nil, nil
+}
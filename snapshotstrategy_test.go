@@ -0,0 +1,238 @@
+package eventsource
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type closed struct {
+	*BaseEvent
+}
+
+func (e *closed) Type() EventType {
+	return "closed"
+}
+
+func (e *closed) ApplyTo(context.Context, Aggregate) {}
+
+func TestSnapshotStrategies(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	a := InitAggregate("agg_1", "account")
+	renamedEvent := &renamed{BaseEvent: NewBaseEvent(a, nil)}
+	closedEvent := &closed{BaseEvent: NewBaseEvent(a, nil)}
+
+	tests := []struct {
+		name     string
+		strategy SnapshotStrategy
+		context  SnapshotContext
+		want     bool
+	}{
+		{
+			name:     "never",
+			strategy: NeverSnapshot(),
+			context:  SnapshotContext{Event: renamedEvent, Version: 10},
+			want:     false,
+		},
+		{
+			name:     "every 5 events at a multiple",
+			strategy: EveryNEvents(5),
+			context:  SnapshotContext{Event: renamedEvent, Version: 10},
+			want:     true,
+		},
+		{
+			name:     "every 5 events between multiples",
+			strategy: EveryNEvents(5),
+			context:  SnapshotContext{Event: renamedEvent, Version: 11},
+			want:     false,
+		},
+		{
+			name:     "on event types",
+			strategy: OnEventTypes("closed"),
+			context:  SnapshotContext{Event: closedEvent, Version: 3},
+			want:     true,
+		},
+		{
+			name:     "on other event types",
+			strategy: OnEventTypes("closed"),
+			context:  SnapshotContext{Event: renamedEvent, Version: 3},
+			want:     false,
+		},
+		{
+			name:     "replay cost reached",
+			strategy: ReplayCost(4),
+			context:  SnapshotContext{Event: renamedEvent, Version: 6, LastSnapshotVersion: 2},
+			want:     true,
+		},
+		{
+			name:     "replay cost not reached",
+			strategy: ReplayCost(4),
+			context:  SnapshotContext{Event: renamedEvent, Version: 5, LastSnapshotVersion: 2},
+			want:     false,
+		},
+		{
+			name:     "recent snapshot",
+			strategy: AfterDuration(time.Hour),
+			context:  SnapshotContext{Event: renamedEvent, Version: 4, LastSnapshotTakenAt: now.Add(-time.Minute), Now: now},
+			want:     false,
+		},
+		{
+			name:     "stale snapshot",
+			strategy: AfterDuration(time.Hour),
+			context:  SnapshotContext{Event: renamedEvent, Version: 4, LastSnapshotTakenAt: now.Add(-2 * time.Hour), Now: now},
+			want:     true,
+		},
+		{
+			name:     "any of",
+			strategy: AnyOf(EveryNEvents(5), OnEventTypes("closed")),
+			context:  SnapshotContext{Event: closedEvent, Version: 7},
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.strategy.ShouldSnapshot(ctx, tt.context); got != tt.want {
+				t.Errorf("ShouldSnapshot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	// a is renamed 5 times on top of a stored version 3 and closed at
+	// version 9.
+	a := InitAggregate("agg_1", "account")
+	a.SetVersion(3)
+
+	for i := 0; i < 5; i++ {
+		_ = Raise(ctx, a, &renamed{BaseEvent: NewBaseEvent(a, nil)})
+	}
+
+	_ = Raise(ctx, a, &closed{BaseEvent: NewBaseEvent(a, nil)})
+
+	tests := []struct {
+		name     string
+		strategy SnapshotStrategy
+		last     *Snapshot
+		want     bool
+	}{
+		{
+			name:     "no version selected",
+			strategy: EveryNEvents(10),
+			want:     false,
+		},
+		{
+			name:     "intermediate version selected",
+			strategy: EveryNEvents(3),
+			want:     true,
+		},
+		{
+			name:     "replay cost from the stored snapshot",
+			strategy: ReplayCost(7),
+			last:     &Snapshot{AggregateVersion: 2, SchemaVersion: 1},
+			want:     true,
+		},
+		{
+			name:     "replay cost from the stored snapshot not reached",
+			strategy: ReplayCost(8),
+			last:     &Snapshot{AggregateVersion: 2, SchemaVersion: 1},
+			want:     false,
+		},
+		{
+			name:     "stale snapshot",
+			strategy: OnStaleSnapshot(),
+			last:     &Snapshot{AggregateVersion: 2, SchemaVersion: 2},
+			want:     true,
+		},
+		{
+			name:     "current snapshot",
+			strategy: OnStaleSnapshot(),
+			last:     &Snapshot{AggregateVersion: 2, SchemaVersion: 1},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TakeSnapshot(ctx, tt.strategy, a, tt.last)
+			if err != nil {
+				t.Fatalf("TakeSnapshot() error = %v", err)
+			}
+
+			if (got != nil) != tt.want {
+				t.Fatalf("TakeSnapshot() = %v, want a snapshot: %v", got, tt.want)
+			}
+
+			if got != nil && got.AggregateVersion != 9 {
+				t.Errorf("TakeSnapshot() version = %d, want 9", got.AggregateVersion)
+			}
+		})
+	}
+}
+
+func TestSaveOptions_Strategy(t *testing.T) {
+	ctx := context.Background()
+	c := SnapshotContext{Version: 20}
+
+	if NewSaveOptions(WithSnapshot(0)).Strategy(nil).ShouldSnapshot(ctx, c) {
+		t.Errorf("Strategy() with snapshots disabled selected version 20")
+	}
+
+	if !NewSaveOptions().Strategy(nil).ShouldSnapshot(ctx, c) {
+		t.Errorf("Strategy() by default did not select version 20")
+	}
+
+	if NewSaveOptions(WithSnapshotStrategy(EveryNEvents(15))).Strategy(nil).ShouldSnapshot(ctx, c) {
+		t.Errorf("Strategy() with EveryNEvents(15) selected version 20")
+	}
+}
+
+func TestReadsLastSnapshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy SnapshotStrategy
+		want     bool
+	}{
+		{name: "no strategy", strategy: nil, want: false},
+		{name: "never", strategy: NeverSnapshot(), want: false},
+		{name: "every n events", strategy: EveryNEvents(10), want: false},
+		{name: "on event types", strategy: OnEventTypes("closed"), want: false},
+		{name: "after duration", strategy: AfterDuration(time.Hour), want: true},
+		{name: "replay cost", strategy: ReplayCost(10), want: true},
+		{name: "on stale snapshot", strategy: OnStaleSnapshot(), want: true},
+		{name: "custom strategy", strategy: SnapshotStrategyFunc(func(context.Context, SnapshotContext) bool { return false }), want: true},
+		{name: "any of version strategies", strategy: AnyOf(EveryNEvents(10), OnEventTypes("closed")), want: false},
+		{name: "any of reading the last snapshot", strategy: AnyOf(EveryNEvents(10), ReplayCost(10)), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReadsLastSnapshot(tt.strategy); got != tt.want {
+				t.Errorf("ReadsLastSnapshot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseAggregate_SnapshotsWithFrequency(t *testing.T) {
+	a := InitAggregate("agg_1", "account")
+
+	for v := 1; v <= 6; v++ {
+		a.StackSnapshot(&Snapshot{AggregateVersion: AggregateVersion(v)})
+	}
+
+	if got := len(a.StackedSnapshots()); got != 6 {
+		t.Fatalf("StackedSnapshots() = %d snapshots, want 6", got)
+	}
+
+	got := a.SnapshotsWithFrequency(3)
+	if len(got) != 2 || got[0].AggregateVersion != 3 || got[1].AggregateVersion != 6 {
+		t.Errorf("SnapshotsWithFrequency(3) = %v, want versions 3 and 6", got)
+	}
+
+	if got := a.SnapshotsWithFrequency(0); len(got) != 0 {
+		t.Errorf("SnapshotsWithFrequency(0) = %v, want none", got)
+	}
+}
@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewCheckpointStore returns an eventsource.CheckpointStore persisting the
+// checkpoints of the subscriptions in the checkpoints table.
+func NewCheckpointStore(tracer trace.Tracer, options *Options) (eventsource.CheckpointStore, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkpointStore{
+		options: options,
+		tracer:  tracer,
+	}, nil
+}
+
+type checkpointStore struct {
+	options *Options
+	tracer  trace.Tracer
+}
+
+func (s *checkpointStore) LoadCheckpoint(ctx context.Context, t eventsource.Transaction, name string) (int64, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.checkpointStore.LoadCheckpoint")
+	defer span.End()
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	query := fmt.Sprintf("select position from %s where name = ?; ", s.options.checkpointStorageParams.tableName)
+
+	var position int64
+	if err := tx.QueryRowContext(ctx, query, name).Scan(&position); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, eventsource.ErrNoCheckpointFound
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	return position, nil
+}
+
+func (s *checkpointStore) SaveCheckpoint(ctx context.Context, t eventsource.Transaction, name string, position int64) error {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.checkpointStore.SaveCheckpoint")
+	defer span.End()
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("insert into %s (name, position, updated_at) ", s.options.checkpointStorageParams.tableName))
+	b.WriteString("values (?, ?, ?) ")
+	b.WriteString("on conflict (name) do update ")
+	b.WriteString("set position = excluded.position, updated_at = excluded.updated_at; ")
+
+	if _, err := tx.ExecContext(ctx, b.String(), name, position, time.Now().UTC()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,1004 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/thefabric-io/eventsource"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func NewEventStore(tracer trace.Tracer, options *Options) (eventsource.EventStore, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &eventStore{
+		options: options,
+		tracer:  tracer,
+		table: &snapshotStore{
+			options: options,
+			tracer:  tracer,
+		},
+	}
+
+	s.snapshots = s.table
+	if options.snapshotStore != nil {
+		s.snapshots, s.table = options.snapshotStore, nil
+	}
+
+	return s, nil
+}
+
+type eventStore struct {
+	options   *Options
+	tracer    trace.Tracer
+	snapshots eventsource.SnapshotStore
+	// table is the snapshot store of the snapshots table, nil when the
+	// snapshots are kept in another snapshot store.
+	table *snapshotStore
+}
+
+// Registry returns the registry the events are decoded with, nil when the
+// aggregates parse them.
+func (s *eventStore) Registry() *eventsource.Registry {
+	return s.options.registry
+}
+
+func (s *eventStore) Save(ctx context.Context, t eventsource.Transaction, a eventsource.Aggregate, opts ...eventsource.SaveOption) error {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.Save")
+	defer span.End()
+
+	ctx = eventsource.ContextWithLogger(ctx, s.options.logger)
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	options := eventsource.NewSaveOptions(opts...)
+
+	if options.Expectation == eventsource.ExpectExactVersion {
+		current, err := s.currentVersion(ctx, tx, a.ID())
+		if err != nil {
+			span.RecordError(err)
+
+			return err
+		}
+
+		if err := options.CheckVersion(a, current); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return err
+		}
+	}
+
+	events, err := s.save(ctx, tx, a.Changes())
+	if err != nil {
+		span.RecordError(err)
+
+		return err
+	}
+
+	if err := eventsource.Project(ctx, t, events, s.options.projections...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	if options.WithSnapshot {
+		strategy := options.Strategy(a)
+
+		var last *eventsource.Snapshot
+		if eventsource.ReadsLastSnapshot(strategy) {
+			last, err = s.latestSnapshotInfo(ctx, t, tx, a.ID())
+			if err != nil && !eventsource.ErrIsSnapshotNotFound(err) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return err
+			}
+		}
+
+		snapshot, err := eventsource.TakeSnapshot(ctx, strategy, a, last)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return err
+		}
+
+		switch {
+		case snapshot == nil:
+		case s.table != nil:
+			if err := s.table.save(ctx, tx, snapshot); err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return err
+			}
+
+			if s.options.retention != nil {
+				query, args := pruneStatement(s.table.tableName(), []string{a.ID().String()}, *s.options.retention, time.Now().UTC())
+				if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+
+					return err
+				}
+			}
+		default:
+			if err := s.snapshots.SaveSnapshot(ctx, t, *snapshot); err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return err
+			}
+		}
+	}
+
+	eventsource.LoggerFromContext(ctx).Log(ctx, eventsource.LevelDebug, "events saved",
+		append(eventsource.AggregateFields(a), eventsource.Field{Key: "events", Value: len(events)})...)
+
+	return nil
+}
+
+func (s *eventStore) EventsHistory(ctx context.Context, t eventsource.Transaction, aggregateID, aggregateType string, fromVersion int, limit int) ([]eventsource.EventReadModel, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.EventsHistory")
+	defer span.End()
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	ee, err := s.loadEvents(ctx, tx, eventsource.AggregateID(aggregateID), eventsource.AggregateType(aggregateType), eventsource.AggregateVersion(fromVersion), limit)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return ee, nil
+}
+
+func (s *eventStore) Load(ctx context.Context, t eventsource.Transaction, aggregate eventsource.Aggregate) (eventsource.Aggregate, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.Load")
+	defer span.End()
+
+	return s.load(ctx, t, aggregate, eventsource.LoadTarget{})
+}
+
+func (s *eventStore) LoadAt(ctx context.Context, t eventsource.Transaction, aggregate eventsource.Aggregate, target eventsource.LoadTarget) (eventsource.Aggregate, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.LoadAt")
+	defer span.End()
+
+	return s.load(ctx, t, aggregate, target)
+}
+
+// load loads the aggregate at the target, recording errors on the span of ctx.
+func (s *eventStore) load(ctx context.Context, t eventsource.Transaction, aggregate eventsource.Aggregate, target eventsource.LoadTarget) (eventsource.Aggregate, error) {
+	span := trace.SpanFromContext(ctx)
+
+	ctx = eventsource.ContextWithLogger(ctx, s.options.logger)
+
+	if aggregate.ID().IsZero() || aggregate.Type().IsZero() {
+		return nil, errors.New("aggragate id and type must be specified")
+	}
+
+	aggregate.PrepareForLoading()
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	// bound is the last version to replay, 0 when loading the current
+	// version.
+	bound := eventsource.AggregateVersion(0)
+
+	if target != (eventsource.LoadTarget{}) {
+		bound, err = s.boundOf(ctx, tx, aggregate, target)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		if bound == 0 {
+			err := eventsource.ErrAggregateDoNotExist
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+	}
+
+	snapshot, err := s.loadSnapshot(ctx, t, tx, aggregate, bound)
+	if err != nil {
+		span.RecordError(err)
+
+		return nil, err
+	}
+
+	snapshotExist := false
+	fromVersion := eventsource.AggregateVersion(1)
+
+	if snapshot != nil {
+		fromVersion = snapshot.AggregateVersion.Next()
+		snapshotExist = true
+	}
+
+	var ee []eventsource.EventReadModel
+
+	if bound == 0 || fromVersion <= bound {
+		limit := 0
+		if bound != 0 {
+			limit = int(bound - fromVersion + 1)
+		}
+
+		ee, err = s.loadEvents(ctx, tx, aggregate.ID(), aggregate.Type(), fromVersion, limit)
+		if err != nil {
+			span.RecordError(err)
+
+			return nil, err
+		}
+	}
+
+	if len(ee) == 0 && !snapshotExist {
+		err := eventsource.ErrAggregateDoNotExist
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	events, err := eventsource.ParseEvents(ctx, s.options.registry, aggregate, ee...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	aggregate, err = eventsource.Replay(ctx, aggregate, snapshot, events...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return aggregate, nil
+}
+
+func (s *eventStore) LoadMany(ctx context.Context, t eventsource.Transaction, aggregates ...eventsource.Aggregate) ([]eventsource.LoadResult, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.LoadMany")
+	defer span.End()
+
+	ctx = eventsource.ContextWithLogger(ctx, s.options.logger)
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	results := make([]eventsource.LoadResult, len(aggregates))
+	requested := make([]eventsource.Aggregate, 0, len(aggregates))
+
+	for i, a := range aggregates {
+		if a.ID().IsZero() || a.Type().IsZero() {
+			results[i].Err = errors.New("aggragate id and type must be specified")
+
+			continue
+		}
+
+		a.PrepareForLoading()
+		requested = append(requested, a)
+	}
+
+	snapshots, err := s.loadSnapshots(ctx, t, tx, requested)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	fromVersions := make(map[aggregateKey]eventsource.AggregateVersion, len(requested))
+	for i, a := range requested {
+		from := eventsource.AggregateVersion(1)
+		if snapshots[i] != nil {
+			from = snapshots[i].AggregateVersion.Next()
+		}
+
+		key := aggregateKey{a.ID(), a.Type()}
+		if v, ok := fromVersions[key]; !ok || from < v {
+			fromVersions[key] = from
+		}
+	}
+
+	events, err := s.loadManyEvents(ctx, tx, fromVersions)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	j := 0
+	for i, a := range aggregates {
+		if results[i].Err != nil {
+			continue
+		}
+
+		results[i].Aggregate, results[i].Err = replayFrom(ctx, s.options.registry, a, snapshots[j], events[aggregateKey{a.ID(), a.Type()}])
+		j++
+	}
+
+	return results, nil
+}
+
+// loadSnapshots returns the latest snapshot of each aggregate compatible with
+// its schema version, or nil, in order.
+func (s *eventStore) loadSnapshots(ctx context.Context, t eventsource.Transaction, tx DBTX, aggregates []eventsource.Aggregate) ([]*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.loadSnapshots")
+	defer span.End()
+
+	if s.table == nil {
+		snapshots, err := s.loadExternalSnapshots(ctx, t, tx, aggregates)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		return snapshots, nil
+	}
+
+	snapshots := make([]*eventsource.Snapshot, len(aggregates))
+
+	ids := make([]string, 0, len(aggregates))
+	for _, a := range aggregates {
+		ids = append(ids, a.ID().String())
+	}
+
+	latest, err := s.table.loadLatest(ctx, tx, ids)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	for i, a := range aggregates {
+		schemaVersion := eventsource.SnapshotVersionOf(a)
+
+		for _, snapshot := range latest[aggregateKey{a.ID(), a.Type()}] {
+			if snapshot.SchemaVersion == schemaVersion {
+				snapshots[i] = snapshot
+			}
+		}
+	}
+
+	return snapshots, nil
+}
+
+// loadExternalSnapshots returns the latest snapshot of each aggregate kept in
+// the snapshot store compatible with its schema version, or nil, in order.
+// SnapshotStore has no batch method, so the snapshots are fetched one
+// aggregate at a time; the events they were taken after are then checked with
+// a single query. The snapshots without an event id or whose event is not
+// stored at their version fall back to the search of externalSnapshot.
+func (s *eventStore) loadExternalSnapshots(ctx context.Context, t eventsource.Transaction, tx DBTX, aggregates []eventsource.Aggregate) ([]*eventsource.Snapshot, error) {
+	snapshots := make([]*eventsource.Snapshot, len(aggregates))
+	eventIDs := make([]string, 0, len(aggregates))
+
+	for i, a := range aggregates {
+		snapshot, err := s.snapshots.LatestSnapshot(ctx, t, a.ID(), eventsource.SnapshotVersionOf(a))
+		if eventsource.ErrIsSnapshotNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots[i] = snapshot
+		if !snapshot.EventID.IsZero() {
+			eventIDs = append(eventIDs, snapshot.EventID.String())
+		}
+	}
+
+	stored, err := s.storedEvents(ctx, tx, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, a := range aggregates {
+		snapshot := snapshots[i]
+		if snapshot == nil || stored[snapshot.EventID] == (storedEvent{key: aggregateKey{a.ID(), a.Type()}, version: snapshot.AggregateVersion}) {
+			continue
+		}
+
+		if snapshots[i], err = s.loadSnapshot(ctx, t, tx, a, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return snapshots, nil
+}
+
+// storedEvent is the stream and version an event is stored at.
+type storedEvent struct {
+	key     aggregateKey
+	version eventsource.AggregateVersion
+}
+
+// storedEvents returns the stream and version of the stored events among the
+// ids.
+func (s *eventStore) storedEvents(ctx context.Context, tx DBTX, ids []string) (map[eventsource.EventID]storedEvent, error) {
+	results := make(map[eventsource.EventID]storedEvent, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString("select id, aggregate_id, aggregate_type, aggregate_version ")
+	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
+	b.WriteString(fmt.Sprintf("where id in (%s); ", placeholders(len(ids))))
+
+	rows, err := tx.QueryContext(ctx, b.String(), toArgs(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id, aggregateID, aggregateType string
+			version                        int64
+		)
+
+		if err := rows.Scan(&id, &aggregateID, &aggregateType, &version); err != nil {
+			return nil, err
+		}
+
+		results[eventsource.EventID(id)] = storedEvent{
+			key:     aggregateKey{eventsource.AggregateID(aggregateID), eventsource.AggregateType(aggregateType)},
+			version: eventsource.AggregateVersion(version),
+		}
+	}
+
+	return results, rows.Err()
+}
+
+// aggregateKey identifies the stream of an aggregate: aggregates of distinct
+// types may share an id.
+type aggregateKey struct {
+	id eventsource.AggregateID
+	t  eventsource.AggregateType
+}
+
+// loadManyEvents returns the events of each aggregate from its version, by
+// aggregate and ordered by version.
+func (s *eventStore) loadManyEvents(ctx context.Context, tx DBTX, fromVersions map[aggregateKey]eventsource.AggregateVersion) (map[aggregateKey][]eventsource.EventReadModel, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.loadManyEvents")
+	defer span.End()
+
+	results := make(map[aggregateKey][]eventsource.EventReadModel, len(fromVersions))
+	if len(fromVersions) == 0 {
+		return results, nil
+	}
+
+	values := make([]string, 0, len(fromVersions))
+	args := make([]any, 0, 3*len(fromVersions))
+
+	for key, v := range fromVersions {
+		values = append(values, "(?, ?, ?)")
+		args = append(args, key.id.String(), key.t.String(), v.Int64())
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("with requested(requested_id, requested_type, from_version) as (values %s) ", strings.Join(values, ", ")))
+	b.WriteString(fmt.Sprintf("select %s ", eventColumns))
+	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
+	b.WriteString("join requested ")
+	b.WriteString("on aggregate_id = requested_id ")
+	b.WriteString("and aggregate_type = requested_type ")
+	b.WriteString("and aggregate_version >= from_version ")
+	b.WriteString("order by aggregate_id, aggregate_type, aggregate_version; ")
+
+	events, err := s.queryEvents(ctx, tx, b.String(), args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	for _, e := range events {
+		key := aggregateKey{e.AggregateID, e.AggregateType}
+		results[key] = append(results[key], e)
+	}
+
+	return results, nil
+}
+
+// replayFrom replays the events of the aggregate following the snapshot on top
+// of it, ignoring the events of other aggregate types.
+func replayFrom(ctx context.Context, registry *eventsource.Registry, a eventsource.Aggregate, snapshot *eventsource.Snapshot, ee []eventsource.EventReadModel) (eventsource.Aggregate, error) {
+	fromVersion := eventsource.AggregateVersion(1)
+	if snapshot != nil {
+		fromVersion = snapshot.AggregateVersion.Next()
+	}
+
+	events := make([]eventsource.EventReadModel, 0, len(ee))
+	for _, e := range ee {
+		if e.AggregateVersion >= fromVersion && e.AggregateType == a.Type() {
+			events = append(events, e)
+		}
+	}
+
+	if len(events) == 0 && snapshot == nil {
+		return nil, eventsource.ErrAggregateDoNotExist
+	}
+
+	parsed, err := eventsource.ParseEvents(ctx, registry, a, events...)
+	if err != nil {
+		return nil, err
+	}
+
+	return eventsource.Replay(ctx, a, snapshot, parsed...)
+}
+
+// boundOf returns the last version of the aggregate within the target.
+func (s *eventStore) boundOf(ctx context.Context, tx DBTX, a eventsource.Aggregate, target eventsource.LoadTarget) (eventsource.AggregateVersion, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.boundOf")
+	defer span.End()
+
+	b := strings.Builder{}
+
+	b.WriteString("select coalesce(max(aggregate_version), 0), ")
+	b.WriteString("coalesce(max(case when occurred_at <= ? then aggregate_version end), 0) ")
+	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
+	b.WriteString("where aggregate_id = ? ")
+	b.WriteString("and aggregate_type = ?; ")
+
+	var current, occurred eventsource.AggregateVersion
+	if err := tx.QueryRowContext(ctx, b.String(), target.Time, a.ID().String(), a.Type().String()).Scan(&current, &occurred); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	return target.Bound(current, occurred), nil
+}
+
+func (s *eventStore) ReadAll(ctx context.Context, t eventsource.Transaction, fromPosition int64, opts ...eventsource.ReadOption) ([]eventsource.EventReadModel, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.ReadAll")
+	defer span.End()
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	options := eventsource.NewReadOptions(opts...)
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("select %s ", eventColumns))
+	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
+	b.WriteString("where position > ? ")
+
+	args := []any{
+		fromPosition,
+	}
+
+	if len(options.AggregateTypes) > 0 {
+		b.WriteString(fmt.Sprintf("and aggregate_type in (%s) ", placeholders(len(options.AggregateTypes))))
+		args = append(args, toArgs(options.AggregateTypes)...)
+	}
+
+	if len(options.EventTypes) > 0 {
+		b.WriteString(fmt.Sprintf("and type in (%s) ", placeholders(len(options.EventTypes))))
+		args = append(args, toArgs(options.EventTypes)...)
+	}
+
+	b.WriteString("order by position ")
+	b.WriteString("limit ?; ")
+	args = append(args, options.BatchSize)
+
+	events, err := s.queryEvents(ctx, tx, b.String(), args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// PurgeSnapshots deletes the snapshots of the aggregate type taken with
+// another schema version than schemaVersion. It only applies to the snapshots
+// kept in the snapshots table.
+func (s *eventStore) PurgeSnapshots(ctx context.Context, t eventsource.Transaction, aggregateType eventsource.AggregateType, schemaVersion int) (int64, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.PurgeSnapshots")
+	defer span.End()
+
+	if s.table == nil {
+		err := fmt.Errorf("%w: snapshots are kept in %T", eventsource.ErrSnapshotPurgeNotSupported, s.snapshots)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	deleted, err := s.table.purge(ctx, tx, aggregateType, schemaVersion)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// loadSnapshot returns the latest snapshot of the aggregate at or before the
+// bound, or at any version when it is 0, compatible with its schema version, or
+// nil. Snapshots of another snapshot store left by rolled back transactions
+// are skipped.
+func (s *eventStore) loadSnapshot(ctx context.Context, t eventsource.Transaction, tx DBTX, a eventsource.Aggregate, bound eventsource.AggregateVersion) (*eventsource.Snapshot, error) {
+	var (
+		snapshot *eventsource.Snapshot
+		err      error
+	)
+
+	if s.table != nil {
+		snapshot, err = s.table.load(ctx, tx, a.ID(), bound, eventsource.SnapshotVersionOf(a))
+	} else {
+		snapshot, err = s.externalSnapshot(ctx, t, tx, a, bound)
+	}
+
+	if eventsource.ErrIsSnapshotNotFound(err) {
+		return nil, nil
+	}
+
+	return snapshot, err
+}
+
+// externalSnapshot returns the latest snapshot of the snapshot store at or
+// before the bound, or at the current version when it is 0. The store does
+// not take part in the transaction: the snapshots whose event is not the one
+// stored at their version were left by rolled back saves and are skipped.
+func (s *eventStore) externalSnapshot(ctx context.Context, t eventsource.Transaction, tx DBTX, a eventsource.Aggregate, bound eventsource.AggregateVersion) (*eventsource.Snapshot, error) {
+	if bound == 0 {
+		current, err := s.currentVersion(ctx, tx, a.ID())
+		if err != nil {
+			return nil, err
+		}
+
+		bound = current
+	}
+
+	for bound > 0 {
+		snapshot, err := s.snapshots.SnapshotAt(ctx, t, a.ID(), bound, eventsource.SnapshotVersionOf(a))
+		if err != nil {
+			return nil, err
+		}
+
+		if snapshot.EventID.IsZero() {
+			return snapshot, nil
+		}
+
+		eventID, err := s.eventIDAt(ctx, tx, a.ID(), snapshot.AggregateVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		if eventID == snapshot.EventID {
+			return snapshot, nil
+		}
+
+		bound = snapshot.AggregateVersion - 1
+	}
+
+	return nil, eventsource.ErrNoSnapshotFound
+}
+
+// eventIDAt returns the id of the event stored at the version of the
+// aggregate, or an empty id.
+func (s *eventStore) eventIDAt(ctx context.Context, tx DBTX, id eventsource.AggregateID, version eventsource.AggregateVersion) (eventsource.EventID, error) {
+	query := fmt.Sprintf("select id from %s where aggregate_id = ? and aggregate_version = ?; ", s.eventsTableName())
+
+	var eventID string
+	if err := tx.QueryRowContext(ctx, query, id.String(), version.Int64()).Scan(&eventID); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	return eventsource.EventID(eventID), nil
+}
+
+// latestSnapshotInfo returns the latest snapshot of the aggregate, whatever
+// its schema version, without its data when it is kept in the snapshots table.
+func (s *eventStore) latestSnapshotInfo(ctx context.Context, t eventsource.Transaction, tx DBTX, id eventsource.AggregateID) (*eventsource.Snapshot, error) {
+	if s.table != nil {
+		return s.table.loadInfo(ctx, tx, id)
+	}
+
+	return s.snapshots.LatestSnapshot(ctx, t, id, 0)
+}
+
+// save inserts the events and returns them as read models positioned in the
+// global stream.
+func (s *eventStore) save(ctx context.Context, tx DBTX, events []eventsource.Event) ([]eventsource.EventReadModel, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.save")
+	defer span.End()
+
+	if len(events) == 0 {
+		return nil, eventsource.ErrNoEventsToStore
+	}
+
+	insertBuilder := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Question).
+		Insert(s.eventsTableName()).
+		Columns(
+			"id",
+			"type",
+			"occurred_at",
+			"registered_at",
+			"aggregate_id",
+			"aggregate_type",
+			"aggregate_version",
+			"data",
+			"metadata",
+		)
+
+	sqlEvents := make([]*Event, 0, len(events))
+	for _, e := range events {
+		sqlEvent, err := FromEvent(e)
+		if err != nil {
+			return nil, err
+		}
+
+		insertBuilder = insertBuilder.Values(
+			sqlEvent.ID,
+			sqlEvent.Type,
+			sqlEvent.OccurredAt,
+			sqlEvent.RegisteredAt,
+			sqlEvent.AggregateID,
+			sqlEvent.AggregateType,
+			sqlEvent.AggregateVersion,
+			sqlEvent.Data,
+			sqlEvent.Metadata,
+		)
+
+		sqlEvents = append(sqlEvents, sqlEvent)
+	}
+
+	insertBuilder = insertBuilder.Suffix("returning id, position")
+
+	query, args, err := insertBuilder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("could not build insert statement: %w", err)
+	}
+
+	positions, err := scanPositions(tx.QueryContext(ctx, query, args...))
+	if err != nil {
+		// SQLite rolls back the failing statement alone: none of the events
+		// is inserted and the transaction remains usable to report the
+		// actual version when the versions are already taken.
+		if conflict := s.conflictError(ctx, tx, events[0]); conflict != nil {
+			err = conflict
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	results := make([]eventsource.EventReadModel, 0, len(sqlEvents))
+	for _, sqlEvent := range sqlEvents {
+		sqlEvent.Position = sql.NullInt64{Int64: positions[sqlEvent.ID.String], Valid: true}
+
+		results = append(results, sqlEvent.ToReadModel())
+	}
+
+	return results, nil
+}
+
+// conflictError returns the ConcurrencyConflictError of the first event when
+// its version is already taken, or nil.
+func (s *eventStore) conflictError(ctx context.Context, tx DBTX, first eventsource.Event) error {
+	current, err := s.currentVersion(ctx, tx, first.AggregateID())
+	if err != nil || current < first.AggregateVersion() {
+		return nil
+	}
+
+	return &eventsource.ConcurrencyConflictError{
+		AggregateID:     first.AggregateID(),
+		AggregateType:   first.AggregateType(),
+		ExpectedVersion: first.AggregateVersion() - 1,
+		ActualVersion:   current,
+	}
+}
+
+func (s *eventStore) currentVersion(ctx context.Context, tx DBTX, id eventsource.AggregateID) (eventsource.AggregateVersion, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.currentVersion")
+	defer span.End()
+
+	query := fmt.Sprintf("select coalesce(max(aggregate_version), 0) from %s where aggregate_id = ?; ", s.eventsTableName())
+
+	var version eventsource.AggregateVersion
+	if err := tx.QueryRowContext(ctx, query, id.String()).Scan(&version); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, err
+	}
+
+	return version, nil
+}
+
+func (s *eventStore) loadEvents(ctx context.Context, tx DBTX, id eventsource.AggregateID, aggregateType eventsource.AggregateType, fromVersion eventsource.AggregateVersion, limit int) ([]eventsource.EventReadModel, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.eventStore.loadEvents")
+	defer span.End()
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("select %s ", eventColumns))
+	b.WriteString(fmt.Sprintf("from %s ", s.eventsTableName()))
+	b.WriteString("where aggregate_id = ? ")
+	b.WriteString("and aggregate_version >= ? ")
+	b.WriteString("and aggregate_type = ? ")
+	b.WriteString("order by aggregate_version ")
+
+	args := []any{
+		id.String(),
+		fromVersion.Int64(),
+		aggregateType.String(),
+	}
+
+	if limit != 0 {
+		b.WriteString("limit ?; ")
+		args = append(args, limit)
+	}
+
+	events, err := s.queryEvents(ctx, tx, b.String(), args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return events, nil
+}
+
+const eventColumns = "position, id, type, occurred_at, aggregate_id, aggregate_type, aggregate_version, data, metadata, registered_at"
+
+// queryEvents runs a query selecting the eventColumns and returns the events
+// upcasted to their latest schema version.
+func (s *eventStore) queryEvents(ctx context.Context, tx DBTX, query string, args ...any) ([]eventsource.EventReadModel, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]eventsource.EventReadModel, 0)
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(
+			&event.Position,
+			&event.ID,
+			&event.Type,
+			&event.OccurredAt,
+			&event.AggregateID,
+			&event.AggregateType,
+			&event.AggregateVersion,
+			&event.Data,
+			&event.Metadata,
+			&event.RegisteredAt,
+		); err != nil {
+			return nil, err
+		}
+
+		events = append(events, event.ToReadModel())
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.options.upcasters.UpcastAll(ctx, events...)
+}
+
+func (s *eventStore) eventsTableName() string {
+	return s.options.eventStorageParams.tableName
+}
+
+// scanPositions reads the id and the position of inserted events.
+func scanPositions(rows *sql.Rows, err error) (map[string]int64, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := make(map[string]int64)
+	for rows.Next() {
+		var (
+			id       string
+			position int64
+		)
+
+		if err := rows.Scan(&id, &position); err != nil {
+			return nil, err
+		}
+
+		positions[id] = position
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func toArgs[T ~string](values []T) []any {
+	results := make([]any, 0, len(values))
+	for _, v := range values {
+		results = append(results, string(v))
+	}
+
+	return results
+}
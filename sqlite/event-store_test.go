@@ -0,0 +1,583 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+	"github.com/thefabric-io/eventsource/filesystem"
+	"go.opentelemetry.io/otel/trace"
+	_ "modernc.org/sqlite"
+)
+
+const counterType eventsource.AggregateType = "counter"
+
+type counter struct {
+	*eventsource.BaseAggregate
+	Value int `es:"value"`
+
+	schemaVersion int
+}
+
+func (c *counter) SnapshotVersion() int {
+	if c.schemaVersion == 0 {
+		return 1
+	}
+
+	return c.schemaVersion
+}
+
+func newCounter(id string) *counter {
+	return &counter{BaseAggregate: eventsource.InitAggregate(id, counterType)}
+}
+
+func (c *counter) Increment(ctx context.Context, by int) error {
+	return eventsource.Raise(ctx, c, &incremented{BaseEvent: eventsource.NewBaseEvent(c, nil), By: by})
+}
+
+func (c *counter) ParseEvents(_ context.Context, ee ...eventsource.EventReadModel) []eventsource.Event {
+	results := make([]eventsource.Event, 0, len(ee))
+	for _, e := range ee {
+		switch e.Type {
+		case incrementedType:
+			event := &incremented{BaseEvent: e.InitBaseEvent()}
+			if err := eventsource.UnmarshalES(e.Data, event); err != nil {
+				continue
+			}
+
+			results = append(results, event)
+		}
+	}
+
+	return results
+}
+
+const incrementedType eventsource.EventType = "incremented"
+
+type incremented struct {
+	*eventsource.BaseEvent
+	By int `es:"by"`
+}
+
+func (e *incremented) Type() eventsource.EventType {
+	return incrementedType
+}
+
+func (e *incremented) ApplyTo(_ context.Context, a eventsource.Aggregate) {
+	a.(*counter).Value += e.By
+}
+
+// openDB returns a migrated in-memory database. A single connection is kept
+// open as every connection to ":memory:" opens a distinct database.
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(context.Background(), db, nil); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	return db
+}
+
+func newStore(t *testing.T) eventsource.EventStore {
+	t.Helper()
+
+	store, err := NewEventStore(trace.NewNoopTracerProvider().Tracer(""), nil)
+	if err != nil {
+		t.Fatalf("NewEventStore() error = %v", err)
+	}
+
+	return store
+}
+
+func saveIncrements(t *testing.T, db *sql.DB, store eventsource.EventStore, id string, n int, opts ...eventsource.SaveOption) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	c := newCounter(id)
+	if _, err := store.Load(ctx, tx, c); err != nil && !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		if err := c.Increment(ctx, 1); err != nil {
+			t.Fatalf("Increment() error = %v", err)
+		}
+	}
+
+	if err := store.Save(ctx, tx, c, opts...); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+}
+
+func TestEventStore_SaveAndLoad(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store := newStore(t)
+
+	saveIncrements(t, db, store, "c1", 12)
+	saveIncrements(t, db, store, "c1", 3)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	loaded, err := store.Load(ctx, tx, newCounter("c1"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	c := loaded.(*counter)
+	if c.Value != 15 || c.Version() != 15 {
+		t.Errorf("Load() = value %d at version %d, want 15 at version 15", c.Value, c.Version())
+	}
+
+	snapshot, err := store.(*eventStore).snapshots.LatestSnapshot(ctx, tx, "c1", 1)
+	if err != nil {
+		t.Fatalf("LatestSnapshot() error = %v", err)
+	}
+
+	if snapshot.AggregateVersion != 12 || snapshot.TakenAt.IsZero() {
+		t.Errorf("LatestSnapshot() = version %d taken at %v, want version 12", snapshot.AggregateVersion, snapshot.TakenAt)
+	}
+
+	history, err := store.EventsHistory(ctx, tx, "c1", counterType.String(), 14, 0)
+	if err != nil {
+		t.Fatalf("EventsHistory() error = %v", err)
+	}
+
+	if len(history) != 2 || history[0].AggregateVersion != 14 || history[0].OccurredAt.IsZero() {
+		t.Errorf("EventsHistory() = %v, want versions 14 and 15", history)
+	}
+
+	if _, err := store.Load(ctx, tx, newCounter("unknown")); !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+		t.Errorf("Load() error = %v, want %v", err, eventsource.ErrAggregateDoNotExist)
+	}
+}
+
+func TestEventStore_LoadAt(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store := newStore(t)
+
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+
+	saveIncrements(t, db, store, "c1", 12)
+
+	between := time.Now()
+	time.Sleep(time.Millisecond)
+
+	saveIncrements(t, db, store, "c1", 3)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	tests := []struct {
+		name   string
+		target eventsource.LoadTarget
+		want   eventsource.AggregateVersion
+	}{
+		{name: "before the first snapshot", target: eventsource.AtVersion(5), want: 5},
+		{name: "at a snapshot", target: eventsource.AtVersion(12), want: 12},
+		{name: "after a snapshot", target: eventsource.AtVersion(14), want: 14},
+		{name: "beyond the stream", target: eventsource.AtVersion(100), want: 15},
+		{name: "at a time", target: eventsource.AtTime(between), want: 12},
+		{name: "current", want: 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := store.LoadAt(ctx, tx, newCounter("c1"), tt.target)
+			if err != nil {
+				t.Fatalf("LoadAt() error = %v", err)
+			}
+
+			if c := loaded.(*counter); c.Value != int(tt.want) || c.Version() != tt.want {
+				t.Errorf("LoadAt() = value %d at version %d, want %d", c.Value, c.Version(), tt.want)
+			}
+		})
+	}
+
+	if _, err := store.LoadAt(ctx, tx, newCounter("c1"), eventsource.AtTime(before)); !errors.Is(err, eventsource.ErrAggregateDoNotExist) {
+		t.Errorf("LoadAt() before the first event error = %v, want ErrAggregateDoNotExist", err)
+	}
+}
+
+func TestEventStore_LoadMany(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store := newStore(t)
+
+	saveIncrements(t, db, store, "c1", 12)
+	saveIncrements(t, db, store, "c1", 3)
+	saveIncrements(t, db, store, "c2", 4)
+	saveIncrements(t, db, store, "c3", 10)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	// c3 is loaded with another snapshot schema version and is replayed
+	// from its first event.
+	c3 := newCounter("c3")
+	c3.schemaVersion = 2
+
+	// The stream of c1 and its snapshots are not loaded for another type.
+	other := &counter{BaseAggregate: eventsource.InitAggregate("c1", "other")}
+
+	results, err := store.LoadMany(ctx, tx, newCounter("c1"), newCounter("missing"), newCounter("c2"), c3, other)
+	if err != nil {
+		t.Fatalf("LoadMany() error = %v", err)
+	}
+
+	if len(results) != 5 {
+		t.Fatalf("LoadMany() = %d results, want 5", len(results))
+	}
+
+	if !errors.Is(results[1].Err, eventsource.ErrAggregateDoNotExist) {
+		t.Errorf("LoadMany() missing error = %v, want ErrAggregateDoNotExist", results[1].Err)
+	}
+
+	if !errors.Is(results[4].Err, eventsource.ErrAggregateDoNotExist) {
+		t.Errorf("LoadMany() of another type error = %v, want ErrAggregateDoNotExist", results[4].Err)
+	}
+
+	for i, want := range map[int]int{0: 15, 2: 4, 3: 10} {
+		if results[i].Err != nil {
+			t.Fatalf("LoadMany() result %d error = %v", i, results[i].Err)
+		}
+
+		if c := results[i].Aggregate.(*counter); c.Value != want || c.Version() != eventsource.AggregateVersion(want) {
+			t.Errorf("LoadMany() result %d = value %d at version %d, want %d", i, c.Value, c.Version(), want)
+		}
+	}
+}
+
+func TestEventStore_SnapshotSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store := newStore(t)
+
+	saveIncrements(t, db, store, "c1", 12)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	upgraded := newCounter("c1")
+	upgraded.schemaVersion = 2
+
+	if _, err := store.Load(ctx, tx, upgraded); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if upgraded.Value != 12 || upgraded.Version() != 12 {
+		t.Errorf("Load() = value %d at version %d, want 12 at version 12", upgraded.Value, upgraded.Version())
+	}
+
+	purged, err := eventsource.PurgeOutdatedSnapshots(ctx, store, tx, upgraded)
+	if err != nil {
+		t.Fatalf("PurgeOutdatedSnapshots() error = %v", err)
+	}
+
+	if purged != 1 {
+		t.Errorf("PurgeOutdatedSnapshots() = %d, want 1", purged)
+	}
+}
+
+func TestEventStore_UnversionedSnapshot(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store := newStore(t)
+
+	saveIncrements(t, db, store, "c1", 2)
+
+	snapshots, err := NewSnapshotStore(trace.NewNoopTracerProvider().Tracer(""), nil)
+	if err != nil {
+		t.Fatalf("NewSnapshotStore() error = %v", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	// A snapshot built without a schema version is stored and restored at
+	// the default version.
+	if err := snapshots.SaveSnapshot(ctx, tx, eventsource.Snapshot{
+		AggregateID:      "c1",
+		AggregateType:    counterType,
+		AggregateVersion: 2,
+		TakenAt:          time.Now(),
+		Data:             []byte(`{"value":100}`),
+	}); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+
+	c := newCounter("c1")
+	if _, err := store.Load(ctx, tx, c); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if c.Value != 100 || c.Version() != 2 {
+		t.Errorf("Load() = value %d at version %d, want 100 at version 2 from the snapshot", c.Value, c.Version())
+	}
+}
+
+func TestEventStore_SnapshotStore(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	snapshots, err := filesystem.NewSnapshotStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewSnapshotStore() error = %v", err)
+	}
+
+	store, err := NewEventStore(trace.NewNoopTracerProvider().Tracer(""), NewOptionsBuilder().WithSnapshotStore(snapshots).Build())
+	if err != nil {
+		t.Fatalf("NewEventStore() error = %v", err)
+	}
+
+	saveIncrements(t, db, store, "c1", 12)
+
+	// The snapshot at version 22 of a rolled back save outlives the
+	// transaction.
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+
+	c := newCounter("c1")
+	if _, err := store.Load(ctx, tx, c); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := c.Increment(ctx, 2); err != nil {
+			t.Fatalf("Increment() error = %v", err)
+		}
+	}
+
+	if err := store.Save(ctx, tx, c); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	_ = tx.Rollback()
+
+	saveIncrements(t, db, store, "c1", 10, eventsource.WithSnapshotStrategy(eventsource.NeverSnapshot()))
+	saveIncrements(t, db, store, "c2", 10)
+
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	loaded, err := store.Load(ctx, tx, newCounter("c1"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if c := loaded.(*counter); c.Value != 22 || c.Version() != 22 {
+		t.Errorf("Load() = value %d at version %d, want 22 at version 22", c.Value, c.Version())
+	}
+
+	// LoadMany checks the snapshots of the batch together and searches
+	// further back for the one left by the rolled back save.
+	results, err := store.LoadMany(ctx, tx, newCounter("c1"), newCounter("c2"))
+	if err != nil {
+		t.Fatalf("LoadMany() error = %v", err)
+	}
+
+	for i, want := range []int{22, 10} {
+		if results[i].Err != nil {
+			t.Fatalf("LoadMany() result %d error = %v", i, results[i].Err)
+		}
+
+		if c := results[i].Aggregate.(*counter); c.Value != want || c.Version() != eventsource.AggregateVersion(want) {
+			t.Errorf("LoadMany() result %d = value %d at version %d, want %d", i, c.Value, c.Version(), want)
+		}
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store := newStore(t)
+
+	every := eventsource.WithSnapshotStrategy(eventsource.EveryNEvents(1))
+
+	for _, id := range []string{"c1", "c2", "c3"} {
+		for i := 0; i < 3; i++ {
+			saveIncrements(t, db, store, id, 1, every)
+		}
+	}
+
+	deleted, err := PruneSnapshots(ctx, db, nil, eventsource.KeepLast(2), eventsource.WithPruneChunkSize(2))
+	if err != nil {
+		t.Fatalf("PruneSnapshots() error = %v", err)
+	}
+
+	if deleted != 3 {
+		t.Errorf("PruneSnapshots() = %d, want 3", deleted)
+	}
+
+	retaining, err := NewEventStore(trace.NewNoopTracerProvider().Tracer(""), NewOptionsBuilder().WithSnapshotRetention(eventsource.KeepLast(1)).Build())
+	if err != nil {
+		t.Fatalf("NewEventStore() error = %v", err)
+	}
+
+	saveIncrements(t, db, retaining, "c1", 1, every)
+
+	var count int
+	if err := db.QueryRowContext(ctx, "select count(*) from snapshots where aggregate_id = 'c1';").Scan(&count); err != nil {
+		t.Fatalf("QueryRowContext() error = %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("Save() with retention kept %d snapshots, want 1", count)
+	}
+}
+
+func TestEventStore_ConcurrencyConflict(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store := newStore(t)
+
+	saveIncrements(t, db, store, "c1", 2)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	stale := newCounter("c1")
+	for i := 0; i < 3; i++ {
+		stale.Increment(ctx, 1)
+	}
+
+	err = store.Save(ctx, tx, stale, eventsource.ExpectAny())
+
+	var conflict *eventsource.ConcurrencyConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Save() error = %v, want %v", err, eventsource.ErrConcurrencyConflict)
+	}
+
+	if conflict.ExpectedVersion != 0 || conflict.ActualVersion != 2 {
+		t.Errorf("Save() conflict = expected %d actual %d, want expected 0 actual 2", conflict.ExpectedVersion, conflict.ActualVersion)
+	}
+
+	// The events of the conflicting save following the stored versions are
+	// not inserted either.
+	history, err := store.EventsHistory(ctx, tx, "c1", counterType.String(), 1, 0)
+	if err != nil {
+		t.Fatalf("EventsHistory() error = %v", err)
+	}
+
+	if len(history) != 2 {
+		t.Errorf("EventsHistory() = %d events after the conflict, want 2", len(history))
+	}
+}
+
+func TestEventStore_ReadAll(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store := newStore(t)
+
+	saveIncrements(t, db, store, "c1", 2)
+	saveIncrements(t, db, store, "c2", 3)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	events, err := store.ReadAll(ctx, tx, 1, eventsource.WithEventTypes(incrementedType), eventsource.WithBatchSize(3))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("ReadAll() returned %d events, want 3", len(events))
+	}
+
+	for i, e := range events {
+		if e.Position != int64(i+2) {
+			t.Errorf("ReadAll()[%d].Position = %d, want %d", i, e.Position, i+2)
+		}
+	}
+
+	events, err = store.ReadAll(ctx, tx, 0, eventsource.WithAggregateTypes("unknown"))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	if len(events) != 0 {
+		t.Errorf("ReadAll() returned %d events, want 0", len(events))
+	}
+}
+
+func TestCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	checkpoints, err := NewCheckpointStore(trace.NewNoopTracerProvider().Tracer(""), nil)
+	if err != nil {
+		t.Fatalf("NewCheckpointStore() error = %v", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := checkpoints.LoadCheckpoint(ctx, tx, "sub"); !errors.Is(err, eventsource.ErrNoCheckpointFound) {
+		t.Errorf("LoadCheckpoint() error = %v, want %v", err, eventsource.ErrNoCheckpointFound)
+	}
+
+	for _, position := range []int64{3, 7} {
+		if err := checkpoints.SaveCheckpoint(ctx, tx, "sub", position); err != nil {
+			t.Fatalf("SaveCheckpoint() error = %v", err)
+		}
+	}
+
+	if position, err := checkpoints.LoadCheckpoint(ctx, tx, "sub"); err != nil || position != 7 {
+		t.Errorf("LoadCheckpoint() = %d, %v, want 7", position, err)
+	}
+}
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+type Event struct {
+	Position         sql.NullInt64
+	ID               sql.NullString
+	Type             sql.NullString
+	OccurredAt       sql.NullTime
+	RegisteredAt     sql.NullTime
+	AggregateID      sql.NullString
+	AggregateType    sql.NullString
+	AggregateVersion sql.NullInt64
+	Data             sql.NullString
+	Metadata         sql.NullString
+}
+
+func FromEvent(event eventsource.Event) (*Event, error) {
+	data, err := eventsource.MarshalES(event)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := eventsource.MarshalES(eventsource.EventMetadata(event))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		ID:               sql.NullString{String: event.ID().String(), Valid: !event.ID().IsZero()},
+		Type:             sql.NullString{String: event.Type().String(), Valid: !event.Type().IsZero()},
+		OccurredAt:       sql.NullTime{Time: event.OccurredAt().UTC(), Valid: !event.OccurredAt().IsZero()},
+		RegisteredAt:     sql.NullTime{Time: time.Now().UTC(), Valid: !event.OccurredAt().IsZero()},
+		AggregateID:      sql.NullString{String: event.AggregateID().String(), Valid: !event.AggregateID().IsZero()},
+		AggregateType:    sql.NullString{String: event.AggregateType().String(), Valid: !event.AggregateType().IsZero()},
+		AggregateVersion: sql.NullInt64{Int64: event.AggregateVersion().Int64(), Valid: !event.AggregateVersion().IsZero()},
+		Data:             sql.NullString{String: string(data), Valid: data != nil},
+		Metadata:         sql.NullString{String: string(metadata), Valid: metadata != nil},
+	}, nil
+}
+
+func (e *Event) ToReadModel() eventsource.EventReadModel {
+	var metadata map[string]interface{}
+	_ = json.Unmarshal([]byte(e.Metadata.String), &metadata)
+
+	var data json.RawMessage
+	if e.Data.Valid {
+		data = json.RawMessage(e.Data.String)
+	}
+
+	return eventsource.EventReadModel{
+		Position:         e.Position.Int64,
+		ID:               eventsource.EventID(e.ID.String),
+		Type:             eventsource.EventType(e.Type.String),
+		OccurredAt:       e.OccurredAt.Time,
+		AggregateID:      eventsource.AggregateID(e.AggregateID.String),
+		AggregateType:    eventsource.AggregateType(e.AggregateType.String),
+		AggregateVersion: eventsource.AggregateVersion(e.AggregateVersion.Int64),
+		Metadata:         metadata,
+		Data:             data,
+	}
+}
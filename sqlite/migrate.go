@@ -0,0 +1,223 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// TxBeginner starts the transactions snapshots are pruned in. It is
+// implemented by both *sql.DB and *sqlx.DB.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Conner reserves the connection migrations are applied on. It is implemented
+// by both *sql.DB and *sqlx.DB.
+type Conner interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// migrationBusyTimeout is the least time Migrate waits for the write lock of
+// the database held by another connection.
+const migrationBusyTimeout = 5 * time.Second
+
+// Migration is a versioned migration of the event store schema.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations returns the migrations of the event store schema rendered with
+// the table names of the options, ordered by version.
+func Migrations(options *Options) ([]Migration, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	params := migrationParams{
+		Events:         options.eventStorageParams.tableName,
+		SnapshotsTable: options.snapshotStorageParams.tableName,
+		Snapshots:      options.snapshotStorageParams.tableName,
+		Checkpoints:    options.checkpointStorageParams.tableName,
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+
+		prefix, _, _ := strings.Cut(base, "_")
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name '%s': %w", name, err)
+		}
+
+		tmpl, err := template.ParseFS(migrationFiles, name)
+		if err != nil {
+			return nil, err
+		}
+
+		b := bytes.Buffer{}
+		if err := tmpl.Execute(&b, params); err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    base,
+			SQL:     b.String(),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// migrationParams are the table names the migrations are rendered with. The
+// names of the tables used to name their indexes are kept apart, like in the
+// postgres migrations.
+type migrationParams struct {
+	Events         string
+	SnapshotsTable string
+	Snapshots      string
+	Checkpoints    string
+}
+
+// Migrate creates or upgrades the event store schema by applying, in a single
+// transaction, the migrations that were not applied yet. Applied versions are
+// tracked in the migrations table. The transaction takes the write lock of the
+// database when it begins (begin immediate), waiting at least 5 seconds for
+// other writers, so that concurrent calls apply each migration only once.
+func Migrate(ctx context.Context, db Conner, options *Options) (err error) {
+	options, err = prepareOptions(options)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := Migrations(options)
+	if err != nil {
+		return err
+	}
+
+	// The transaction is run with statements, which database/sql only keeps
+	// on a single connection when it is reserved.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	restore, err := raiseBusyTimeout(ctx, conn, migrationBusyTimeout)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	if _, err := conn.ExecContext(ctx, "begin immediate; "); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_, _ = conn.ExecContext(context.Background(), "rollback; ")
+		}
+	}()
+
+	migrationsTable := options.migrationStorageParams.tableName
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("create table if not exists %s ", migrationsTable))
+	b.WriteString("(version integer primary key, name text, applied_at timestamp); ")
+
+	if _, err := conn.ExecContext(ctx, b.String()); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx, conn, migrationsTable)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, exists := applied[m.Version]; exists {
+			continue
+		}
+
+		if _, err := conn.ExecContext(ctx, m.SQL); err != nil {
+			return fmt.Errorf("could not apply migration '%s': %w", m.Name, err)
+		}
+
+		query := fmt.Sprintf("insert into %s (version, name, applied_at) values (?, ?, ?); ", migrationsTable)
+		if _, err := conn.ExecContext(ctx, query, m.Version, m.Name, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, "commit; ")
+
+	return err
+}
+
+// raiseBusyTimeout raises the busy timeout of the connection to at least d and
+// returns the function restoring it before the connection is released.
+func raiseBusyTimeout(ctx context.Context, conn *sql.Conn, d time.Duration) (func(), error) {
+	var current int64
+	if err := conn.QueryRowContext(ctx, "pragma busy_timeout; ").Scan(&current); err != nil {
+		return nil, err
+	}
+
+	if current >= d.Milliseconds() {
+		return func() {}, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("pragma busy_timeout = %d; ", d.Milliseconds())); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), fmt.Sprintf("pragma busy_timeout = %d; ", current))
+	}, nil
+}
+
+func appliedMigrations(ctx context.Context, tx DBTX, migrationsTable string) (map[int]struct{}, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("select version from %s; ", migrationsTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]struct{})
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = struct{}{}
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestMigrate_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+
+	// Each call runs on a handle of its own, like instances of a service
+	// starting at once.
+	const calls = 16
+
+	var wg sync.WaitGroup
+
+	errs := make([]error, calls)
+	for i := 0; i < calls; i++ {
+		db, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			errs[i] = Migrate(ctx, db, nil)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Migrate() call %d error = %v", i, err)
+		}
+	}
+
+	migrations, err := Migrations(nil)
+	if err != nil {
+		t.Fatalf("Migrations() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	var applied int
+	if err := db.QueryRowContext(ctx, "select count(*) from schema_migrations; ").Scan(&applied); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+
+	if applied != len(migrations) {
+		t.Errorf("Migrate() applied %d migrations, want %d", applied, len(migrations))
+	}
+}
@@ -0,0 +1,25 @@
+create table if not exists {{.Events}}
+(
+    position          integer primary key autoincrement,
+    id                text not null unique,
+    type              text,
+    occurred_at       timestamp,
+    registered_at     timestamp,
+    aggregate_id      text,
+    aggregate_type    text,
+    aggregate_version integer,
+    data              text,
+    metadata          text,
+    unique (aggregate_id, aggregate_version)
+);
+
+create table if not exists {{.Snapshots}}
+(
+    aggregate_id      text,
+    aggregate_type    text,
+    aggregate_version integer,
+    taken_at          timestamp,
+    registered_at     timestamp,
+    data              text,
+    primary key (aggregate_id, aggregate_version)
+);
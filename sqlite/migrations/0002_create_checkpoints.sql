@@ -0,0 +1,6 @@
+create table if not exists {{.Checkpoints}}
+(
+    name       text primary key,
+    position   integer not null,
+    updated_at timestamp
+);
@@ -0,0 +1,191 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+func DefaultOptions() *Options {
+	return &Options{
+		eventStorageParams:      defaultEventStorageParams(),
+		snapshotStorageParams:   defaultSnapshotStorageParams(),
+		checkpointStorageParams: defaultCheckpointStorageParams(),
+		migrationStorageParams:  defaultMigrationStorageParams(),
+	}
+}
+
+type Options struct {
+	eventStorageParams      eventStorageParams
+	snapshotStorageParams   snapshotStorageParams
+	checkpointStorageParams checkpointStorageParams
+	migrationStorageParams  migrationStorageParams
+	logger                  eventsource.Logger
+	snapshotStore           eventsource.SnapshotStore
+	retention               *eventsource.RetentionPolicy
+	registry                *eventsource.Registry
+	upcasters               *eventsource.Upcasters
+	projections             []eventsource.Projection
+}
+
+func (o *Options) Validate() error {
+	if len(strings.TrimSpace(o.eventStorageParams.tableName)) == 0 ||
+		len(strings.TrimSpace(o.snapshotStorageParams.tableName)) == 0 ||
+		len(strings.TrimSpace(o.checkpointStorageParams.tableName)) == 0 ||
+		len(strings.TrimSpace(o.migrationStorageParams.tableName)) == 0 {
+		return fmt.Errorf("options invalid")
+	}
+
+	return nil
+}
+
+func (o *Options) IsZero() bool {
+	return o.eventStorageParams == eventStorageParams{} &&
+		o.snapshotStorageParams == snapshotStorageParams{} &&
+		o.checkpointStorageParams == checkpointStorageParams{} &&
+		o.migrationStorageParams == migrationStorageParams{} &&
+		o.logger == nil &&
+		o.snapshotStore == nil &&
+		o.retention == nil &&
+		o.registry == nil &&
+		o.upcasters == nil &&
+		len(o.projections) == 0
+}
+
+// prepareOptions returns the options to use for a store, defaulting them when
+// none are given.
+func prepareOptions(options *Options) (*Options, error) {
+	if options == nil || options.IsZero() {
+		options = DefaultOptions()
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+func NewOptionsBuilder() *OptionsBuilder {
+	return &OptionsBuilder{options: DefaultOptions()}
+}
+
+type OptionsBuilder struct {
+	options *Options
+}
+
+func (b *OptionsBuilder) WithEventStorageTableName(name string) *OptionsBuilder {
+	b.options.eventStorageParams.tableName = name
+
+	return b
+}
+
+func (b *OptionsBuilder) WithSnapshotStorageTableName(name string) *OptionsBuilder {
+	b.options.snapshotStorageParams.tableName = name
+
+	return b
+}
+
+func (b *OptionsBuilder) WithCheckpointStorageTableName(name string) *OptionsBuilder {
+	b.options.checkpointStorageParams.tableName = name
+
+	return b
+}
+
+func (b *OptionsBuilder) WithMigrationStorageTableName(name string) *OptionsBuilder {
+	b.options.migrationStorageParams.tableName = name
+
+	return b
+}
+
+// WithLogger makes the stores log through l rather than the global logger,
+// including while replaying the aggregates they load.
+func (b *OptionsBuilder) WithLogger(l eventsource.Logger) *OptionsBuilder {
+	b.options.logger = l
+
+	return b
+}
+
+// WithSnapshotStore makes the event store keep the snapshots in ss rather than
+// in the snapshots table.
+func (b *OptionsBuilder) WithSnapshotStore(ss eventsource.SnapshotStore) *OptionsBuilder {
+	b.options.snapshotStore = ss
+
+	return b
+}
+
+// WithSnapshotRetention makes the event store prune the snapshots of an
+// aggregate that the policy does not keep whenever Save snapshots it. It only
+// applies to the snapshots kept in the snapshots table.
+func (b *OptionsBuilder) WithSnapshotRetention(policy eventsource.RetentionPolicy) *OptionsBuilder {
+	b.options.retention = &policy
+
+	return b
+}
+
+// WithEventRegistry makes the event store decode loaded events with the
+// registry instead of the aggregate's ParseEvents.
+func (b *OptionsBuilder) WithEventRegistry(r *eventsource.Registry) *OptionsBuilder {
+	b.options.registry = r
+
+	return b
+}
+
+// WithUpcasters makes the event store upcast the events it reads to their
+// latest schema version.
+func (b *OptionsBuilder) WithUpcasters(u *eventsource.Upcasters) *OptionsBuilder {
+	b.options.upcasters = u
+
+	return b
+}
+
+// WithInlineProjections makes the event store project the saved events within
+// the transaction of Save.
+func (b *OptionsBuilder) WithInlineProjections(projections ...eventsource.Projection) *OptionsBuilder {
+	b.options.projections = append(b.options.projections, projections...)
+
+	return b
+}
+
+func (b *OptionsBuilder) Build() *Options {
+	return b.options
+}
+
+func defaultEventStorageParams() eventStorageParams {
+	return eventStorageParams{tableName: "events"}
+}
+
+type eventStorageParams struct {
+	tableName string
+}
+
+func defaultSnapshotStorageParams() snapshotStorageParams {
+	return snapshotStorageParams{
+		tableName: "snapshots",
+	}
+}
+
+type snapshotStorageParams struct {
+	tableName string
+}
+
+func defaultCheckpointStorageParams() checkpointStorageParams {
+	return checkpointStorageParams{
+		tableName: "checkpoints",
+	}
+}
+
+type checkpointStorageParams struct {
+	tableName string
+}
+
+func defaultMigrationStorageParams() migrationStorageParams {
+	return migrationStorageParams{
+		tableName: "schema_migrations",
+	}
+}
+
+type migrationStorageParams struct {
+	tableName string
+}
@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+// PruneSnapshots deletes the snapshots that the policy does not keep and
+// returns their number. Aggregates are processed in the order of their ids,
+// a chunk of them per transaction, so that writers are not blocked for long on
+// large databases.
+func PruneSnapshots(ctx context.Context, db TxBeginner, options *Options, policy eventsource.RetentionPolicy, opts ...eventsource.PruneOption) (int64, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return 0, err
+	}
+
+	pruneOptions := eventsource.NewPruneOptions(opts...)
+	table := options.snapshotStorageParams.tableName
+
+	var (
+		total  int64
+		cursor string
+	)
+
+	for {
+		deleted, last, err := pruneSnapshotsChunk(ctx, db, table, policy, cursor, pruneOptions.ChunkSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+
+		if last == "" {
+			return total, nil
+		}
+
+		cursor = last
+	}
+}
+
+// pruneSnapshotsChunk prunes the snapshots of the chunk of aggregates following
+// the cursor and returns the id of the last of them, or an empty id when there
+// are none left.
+func pruneSnapshotsChunk(ctx context.Context, db TxBeginner, table string, policy eventsource.RetentionPolicy, cursor string, size int) (int64, string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	b := strings.Builder{}
+
+	b.WriteString("select distinct aggregate_id ")
+	b.WriteString(fmt.Sprintf("from %s ", table))
+	b.WriteString("where aggregate_id > ? ")
+	b.WriteString("order by aggregate_id ")
+	b.WriteString("limit ?; ")
+
+	rows, err := tx.QueryContext(ctx, b.String(), cursor, size)
+	if err != nil {
+		return 0, "", err
+	}
+
+	ids := make([]string, 0, size)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+
+			return 0, "", err
+		}
+
+		ids = append(ids, id)
+	}
+
+	_ = rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+
+	if len(ids) == 0 {
+		return 0, "", tx.Commit()
+	}
+
+	query, args := pruneStatement(table, ids, policy, time.Now().UTC())
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, "", err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+
+	return deleted, ids[len(ids)-1], nil
+}
+
+// pruneStatement deletes the snapshots of the aggregates with the given ids
+// that the policy does not keep.
+func pruneStatement(table string, ids []string, policy eventsource.RetentionPolicy, now time.Time) (string, []any) {
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("delete from %s ", table))
+	b.WriteString("where (aggregate_id, aggregate_version) in (")
+	b.WriteString("select aggregate_id, aggregate_version from (")
+	b.WriteString("select aggregate_id, aggregate_version, taken_at, ")
+	b.WriteString("row_number() over (partition by aggregate_id order by aggregate_version desc) as rank ")
+	b.WriteString(fmt.Sprintf("from %s ", table))
+	b.WriteString(fmt.Sprintf("where aggregate_id in (%s)", placeholders(len(ids))))
+	b.WriteString(") ranked ")
+	b.WriteString("where rank > ?")
+
+	args := toArgs(ids)
+	args = append(args, policy.KeptNewest())
+
+	if cutoff := policy.Cutoff(now); !cutoff.IsZero() {
+		args = append(args, cutoff)
+		b.WriteString(" and taken_at < ?")
+	}
+
+	b.WriteString("); ")
+
+	return b.String(), args
+}
@@ -0,0 +1,317 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/thefabric-io/eventsource"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewSnapshotStore returns an eventsource.SnapshotStore keeping the snapshots
+// in the snapshots table. It is the snapshot store of the event store unless
+// the options are given another one with WithSnapshotStore.
+func NewSnapshotStore(tracer trace.Tracer, options *Options) (eventsource.SnapshotStore, error) {
+	options, err := prepareOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshotStore{
+		options: options,
+		tracer:  tracer,
+	}, nil
+}
+
+type snapshotStore struct {
+	options *Options
+	tracer  trace.Tracer
+}
+
+func (s *snapshotStore) SaveSnapshot(ctx context.Context, t eventsource.Transaction, snapshot eventsource.Snapshot) error {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.snapshotStore.SaveSnapshot")
+	defer span.End()
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	if err := s.save(ctx, tx, &snapshot); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *snapshotStore) LatestSnapshot(ctx context.Context, t eventsource.Transaction, id eventsource.AggregateID, schemaVersion int) (*eventsource.Snapshot, error) {
+	return s.SnapshotAt(ctx, t, id, 0, schemaVersion)
+}
+
+func (s *snapshotStore) SnapshotAt(ctx context.Context, t eventsource.Transaction, id eventsource.AggregateID, version eventsource.AggregateVersion, schemaVersion int) (*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.snapshotStore.SnapshotAt")
+	defer span.End()
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return s.load(ctx, tx, id, version, schemaVersion)
+}
+
+func (s *snapshotStore) DeleteSnapshots(ctx context.Context, t eventsource.Transaction, id eventsource.AggregateID, versions ...eventsource.AggregateVersion) error {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.snapshotStore.DeleteSnapshots")
+	defer span.End()
+
+	tx, err := dbtx(t)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("delete from %s ", s.tableName()))
+	b.WriteString("where aggregate_id = ? ")
+
+	args := []any{
+		id.String(),
+	}
+
+	if len(versions) > 0 {
+		b.WriteString(fmt.Sprintf("and aggregate_version in (%s) ", placeholders(len(versions))))
+
+		for _, v := range versions {
+			args = append(args, v.Int64())
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, b.String(), args...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *snapshotStore) save(ctx context.Context, tx DBTX, ss ...*eventsource.Snapshot) error {
+	insertBuilder := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Question).
+		Insert(s.tableName()).
+		Columns(
+			"aggregate_id",
+			"aggregate_type",
+			"aggregate_version",
+			"schema_version",
+			"taken_at",
+			"registered_at",
+			"data",
+		)
+
+	for _, snap := range ss {
+		sqlSnap := FromSnapshot(*snap)
+		insertBuilder = insertBuilder.Values(
+			sqlSnap.AggregateID,
+			sqlSnap.AggregateType,
+			sqlSnap.AggregateVersion,
+			sqlSnap.SchemaVersion,
+			sqlSnap.TakenAt,
+			time.Now().UTC(),
+			sqlSnap.Data,
+		)
+	}
+
+	query, args, err := insertBuilder.ToSql()
+	if err != nil {
+		return fmt.Errorf("could not build insert statement: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// load returns the latest snapshot of the aggregate at or before the version,
+// or at any version when it is 0, taken with the schema version, or with any
+// schema version when it is 0.
+func (s *snapshotStore) load(ctx context.Context, tx DBTX, id eventsource.AggregateID, version eventsource.AggregateVersion, schemaVersion int) (*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.snapshotStore.load")
+	defer span.End()
+
+	b := strings.Builder{}
+
+	b.WriteString("select aggregate_id, aggregate_type, aggregate_version, schema_version, taken_at, data ")
+	b.WriteString(fmt.Sprintf("from %s ", s.tableName()))
+	b.WriteString("where aggregate_id = ? ")
+
+	args := []any{
+		id.String(),
+	}
+
+	if version != 0 {
+		b.WriteString("and aggregate_version <= ? ")
+		args = append(args, version.Int64())
+	}
+
+	if schemaVersion != 0 {
+		b.WriteString("and schema_version = ? ")
+		args = append(args, schemaVersion)
+	}
+
+	b.WriteString("order by aggregate_version desc ")
+	b.WriteString("limit 1; ")
+
+	snapshot := Snapshot{}
+	if err := tx.QueryRowContext(ctx, b.String(), args...).Scan(
+		&snapshot.AggregateID,
+		&snapshot.AggregateType,
+		&snapshot.AggregateVersion,
+		&snapshot.SchemaVersion,
+		&snapshot.TakenAt,
+		&snapshot.Data,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, eventsource.ErrNoSnapshotFound
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return snapshot.ToSnapshot(), nil
+}
+
+// loadLatest returns the latest snapshot of each schema version of the
+// aggregates, by aggregate id and type.
+func (s *snapshotStore) loadLatest(ctx context.Context, tx DBTX, ids []string) (map[aggregateKey][]*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.snapshotStore.loadLatest")
+	defer span.End()
+
+	results := make(map[aggregateKey][]*eventsource.Snapshot, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	b := strings.Builder{}
+
+	b.WriteString("select aggregate_id, aggregate_type, aggregate_version, schema_version, taken_at, data ")
+	b.WriteString("from (")
+	b.WriteString("select *, row_number() over (partition by aggregate_id, aggregate_type, schema_version order by aggregate_version desc) as rank ")
+	b.WriteString(fmt.Sprintf("from %s ", s.tableName()))
+	b.WriteString(fmt.Sprintf("where aggregate_id in (%s)", placeholders(len(ids))))
+	b.WriteString(") as latest ")
+	b.WriteString("where rank = 1; ")
+
+	rows, err := tx.QueryContext(ctx, b.String(), toArgs(ids)...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		snapshot := Snapshot{}
+		if err := rows.Scan(
+			&snapshot.AggregateID,
+			&snapshot.AggregateType,
+			&snapshot.AggregateVersion,
+			&snapshot.SchemaVersion,
+			&snapshot.TakenAt,
+			&snapshot.Data,
+		); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		ss := snapshot.ToSnapshot()
+		key := aggregateKey{ss.AggregateID, ss.AggregateType}
+		results[key] = append(results[key], ss)
+	}
+
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// loadInfo returns the version, the schema version and the time of the latest
+// snapshot of the aggregate, without its data.
+func (s *snapshotStore) loadInfo(ctx context.Context, tx DBTX, id eventsource.AggregateID) (*eventsource.Snapshot, error) {
+	ctx, span := s.tracer.Start(ctx, "eventsource.sqlite.snapshotStore.loadInfo")
+	defer span.End()
+
+	b := strings.Builder{}
+
+	b.WriteString("select aggregate_version, schema_version, taken_at ")
+	b.WriteString(fmt.Sprintf("from %s ", s.tableName()))
+	b.WriteString("where aggregate_id = ? ")
+	b.WriteString("order by aggregate_version desc ")
+	b.WriteString("limit 1; ")
+
+	snapshot := Snapshot{}
+	if err := tx.QueryRowContext(ctx, b.String(), id.String()).Scan(&snapshot.AggregateVersion, &snapshot.SchemaVersion, &snapshot.TakenAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, eventsource.ErrNoSnapshotFound
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	snapshot.AggregateID = sql.NullString{String: id.String(), Valid: true}
+
+	return snapshot.ToSnapshot(), nil
+}
+
+// purge deletes the snapshots of the aggregate type taken with another schema
+// version than schemaVersion.
+func (s *snapshotStore) purge(ctx context.Context, tx DBTX, aggregateType eventsource.AggregateType, schemaVersion int) (int64, error) {
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("delete from %s ", s.tableName()))
+	b.WriteString("where aggregate_type = ? ")
+	b.WriteString("and schema_version <> ?; ")
+
+	result, err := tx.ExecContext(ctx, b.String(), aggregateType.String(), schemaVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *snapshotStore) tableName() string {
+	return s.options.snapshotStorageParams.tableName
+}
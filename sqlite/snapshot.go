@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+func FromSnapshot(s eventsource.Snapshot) *Snapshot {
+	return &Snapshot{
+		AggregateID: sql.NullString{
+			String: s.AggregateID.String(),
+			Valid:  !s.AggregateID.IsZero(),
+		},
+		AggregateType: sql.NullString{
+			String: s.AggregateType.String(),
+			Valid:  !s.AggregateType.IsZero(),
+		},
+		AggregateVersion: sql.NullInt64{
+			Int64: s.AggregateVersion.Int64(),
+			Valid: !s.AggregateVersion.IsZero(),
+		},
+		SchemaVersion: sql.NullInt64{
+			Int64: int64(eventsource.NormalizeSnapshotVersion(s.SchemaVersion)),
+			Valid: true,
+		},
+		TakenAt: sql.NullTime{
+			Time:  s.TakenAt.UTC(),
+			Valid: !s.TakenAt.IsZero(),
+		},
+		Data: sql.NullString{
+			String: string(s.Data),
+			Valid:  s.Data != nil,
+		},
+	}
+}
+
+type Snapshot struct {
+	AggregateID      sql.NullString
+	AggregateType    sql.NullString
+	AggregateVersion sql.NullInt64
+	SchemaVersion    sql.NullInt64
+	TakenAt          sql.NullTime
+	Data             sql.NullString
+}
+
+func (s *Snapshot) ToSnapshot() *eventsource.Snapshot {
+	return &eventsource.Snapshot{
+		AggregateID:      eventsource.AggregateID(s.AggregateID.String),
+		AggregateType:    eventsource.AggregateType(s.AggregateType.String),
+		AggregateVersion: eventsource.AggregateVersion(s.AggregateVersion.Int64),
+		SchemaVersion:    int(s.SchemaVersion.Int64),
+		TakenAt:          s.TakenAt.Time,
+		Data:             []byte(s.Data.String),
+	}
+}
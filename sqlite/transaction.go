@@ -0,0 +1,31 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/thefabric-io/eventsource"
+)
+
+// DBTX is the subset of the methods of *sql.Tx and *sqlx.Tx used by the
+// stores. Transactions given to the stores must implement it, directly or
+// wrapped through eventsource.TransactionWrapper.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func dbtx(t eventsource.Transaction) (DBTX, error) {
+	if t == nil {
+		return nil, eventsource.ErrTransactionIsRequired
+	}
+
+	tx, ok := eventsource.TransactionAs[DBTX](t)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T does not implement sqlite.DBTX", eventsource.ErrUnsupportedTransaction, t)
+	}
+
+	return tx, nil
+}
@@ -0,0 +1,202 @@
+package eventsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNoCheckpointFound = errors.New("no checkpoint found")
+)
+
+// BeginFunc starts the transactions used by long-running components such as
+// subscriptions, e.g. a wrapper around sqlx.DB.BeginTxx.
+type BeginFunc func(ctx context.Context) (Transaction, error)
+
+// CheckpointStore persists the position of the last event processed by each
+// subscription.
+type CheckpointStore interface {
+	// LoadCheckpoint returns ErrNoCheckpointFound when the subscription has
+	// not saved any checkpoint yet.
+	LoadCheckpoint(ctx context.Context, tx Transaction, name string) (int64, error)
+	SaveCheckpoint(ctx context.Context, tx Transaction, name string, position int64) error
+}
+
+// EventHandler handles the events of a subscription. The transaction is the
+// one the checkpoint is saved with, so that writes made through it are
+// committed atomically with the progress of the subscription.
+type EventHandler interface {
+	Handle(ctx context.Context, tx Transaction, e EventReadModel) error
+}
+
+type EventHandlerFunc func(ctx context.Context, tx Transaction, e EventReadModel) error
+
+func (f EventHandlerFunc) Handle(ctx context.Context, tx Transaction, e EventReadModel) error {
+	return f(ctx, tx, e)
+}
+
+type SubscriptionOption func(*SubscriptionOptions)
+
+// WithPollInterval sets how long a subscription waits for new events once it
+// has caught up with the stream.
+func WithPollInterval(interval time.Duration) SubscriptionOption {
+	return func(opt *SubscriptionOptions) {
+		opt.PollInterval = interval
+	}
+}
+
+// WithReadOptions sets the options used to read the global stream, such as
+// the batch size and the type filters.
+func WithReadOptions(opts ...ReadOption) SubscriptionOption {
+	return func(opt *SubscriptionOptions) {
+		opt.ReadOptions = append(opt.ReadOptions, opts...)
+	}
+}
+
+func NewSubscriptionOptions(opts ...SubscriptionOption) *SubscriptionOptions {
+	const (
+		defaultPollInterval = time.Second
+	)
+
+	result := &SubscriptionOptions{
+		PollInterval: defaultPollInterval,
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+type SubscriptionOptions struct {
+	PollInterval time.Duration
+	ReadOptions  []ReadOption
+}
+
+func NewSubscriber(store EventStore, checkpoints CheckpointStore, begin BeginFunc, opts ...SubscriptionOption) *Subscriber {
+	return &Subscriber{
+		store:       store,
+		checkpoints: checkpoints,
+		begin:       begin,
+		options:     NewSubscriptionOptions(opts...),
+	}
+}
+
+// Subscriber runs catch-up subscriptions on the global stream of an event
+// store. Checkpoints are the position of the last event handled: the store
+// must not return an event preceding the ones it already returned, as
+// ReadAll documents.
+type Subscriber struct {
+	store       EventStore
+	checkpoints CheckpointStore
+	begin       BeginFunc
+	options     *SubscriptionOptions
+}
+
+// Subscribe hands the events of the global stream to the handler, starting
+// after the checkpoint saved under the name of the subscription or after
+// fromCheckpoint when there is none. Each batch of events is handled in its own
+// transaction which also saves the checkpoint. Once caught up, the stream is
+// polled for new events until the context is done or the handler fails.
+func (s *Subscriber) Subscribe(ctx context.Context, name string, fromCheckpoint int64, handler EventHandler) error {
+	return s.subscribe(ctx, name, fromCheckpoint, handler)
+}
+
+func (s *Subscriber) subscribe(ctx context.Context, name string, fromCheckpoint int64, handler EventHandler, opts ...ReadOption) error {
+	readOptions := make([]ReadOption, 0, len(s.options.ReadOptions)+len(opts))
+	readOptions = append(readOptions, s.options.ReadOptions...)
+	readOptions = append(readOptions, opts...)
+
+	options := NewReadOptions(readOptions...)
+
+	for {
+		handled, err := s.handleBatch(ctx, name, fromCheckpoint, handler, readOptions...)
+		if err != nil {
+			return err
+		}
+
+		if handled >= options.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.options.PollInterval):
+		}
+	}
+}
+
+// CatchUp handles the events of the global stream following the checkpoint of
+// the subscription until there are no more, then returns.
+func (s *Subscriber) CatchUp(ctx context.Context, name string, fromCheckpoint int64, handler EventHandler) error {
+	options := NewReadOptions(s.options.ReadOptions...)
+
+	for {
+		handled, err := s.handleBatch(ctx, name, fromCheckpoint, handler, s.options.ReadOptions...)
+		if err != nil {
+			return err
+		}
+
+		if handled < options.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Subscriber) handleBatch(ctx context.Context, name string, fromCheckpoint int64, handler EventHandler, opts ...ReadOption) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	handled, err := s.handleBatchTx(ctx, tx, name, fromCheckpoint, handler, opts...)
+	if err != nil {
+		_ = tx.Rollback()
+
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return handled, nil
+}
+
+func (s *Subscriber) handleBatchTx(ctx context.Context, tx Transaction, name string, fromCheckpoint int64, handler EventHandler, opts ...ReadOption) (int, error) {
+	position, err := s.checkpoints.LoadCheckpoint(ctx, tx, name)
+	if errors.Is(err, ErrNoCheckpointFound) {
+		position = fromCheckpoint
+	} else if err != nil {
+		return 0, err
+	}
+
+	ee, err := s.store.ReadAll(ctx, tx, position, opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ee) == 0 {
+		return 0, nil
+	}
+
+	for _, e := range ee {
+		if err := handler.Handle(ctx, tx, e); err != nil {
+			return 0, fmt.Errorf("subscription '%s' could not handle event '%s' at position %d: %w", name, e.ID, e.Position, err)
+		}
+	}
+
+	if err := s.checkpoints.SaveCheckpoint(ctx, tx, name, ee[len(ee)-1].Position); err != nil {
+		return 0, err
+	}
+
+	return len(ee), nil
+}
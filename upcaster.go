@@ -0,0 +1,146 @@
+package eventsource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// SchemaVersionMetadataKey is the metadata key holding the schema version of
+// the data of a stored event. Events stored without it are at version 1.
+const SchemaVersionMetadataKey = "schema_version"
+
+// SchemaVersioner is implemented by events whose data shape evolved over
+// time. The version is stored in the metadata of the event when it is saved.
+type SchemaVersioner interface {
+	SchemaVersion() int
+}
+
+// EventMetadata returns the metadata to store along with the event, including
+// its schema version when the event implements SchemaVersioner.
+func EventMetadata(e Event) Metadata {
+	v, implements := e.(SchemaVersioner)
+	if !implements {
+		return e.Metadata()
+	}
+
+	metadata := make(Metadata, len(e.Metadata())+1)
+	for key, value := range e.Metadata() {
+		metadata[key] = value
+	}
+
+	return metadata.Add(SchemaVersionMetadataKey, v.SchemaVersion())
+}
+
+// SchemaVersion returns the schema version of the event data as stored in its
+// metadata, or 1 when the event was stored without one.
+func (r *EventReadModel) SchemaVersion() int {
+	switch v := r.Metadata[SchemaVersionMetadataKey].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+
+	return 1
+}
+
+// Upcaster transforms a stored event from one schema version to the next one.
+// It may change the type of the event as well as its data.
+type Upcaster func(ctx context.Context, e EventReadModel) (EventReadModel, error)
+
+func NewUpcasters() *Upcasters {
+	return &Upcasters{
+		steps: make(map[upcasterKey]Upcaster),
+	}
+}
+
+// Upcasters is a chain of upcasters keyed by event type and schema version
+// applied to stored events before they are parsed.
+type Upcasters struct {
+	mu    sync.RWMutex
+	steps map[upcasterKey]Upcaster
+}
+
+type upcasterKey struct {
+	eventType EventType
+	version   int
+}
+
+// Register adds the upcaster transforming events of the type from the schema
+// version to the next one.
+func (u *Upcasters) Register(t EventType, fromVersion int, upcaster Upcaster) *Upcasters {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.steps[upcasterKey{eventType: t, version: fromVersion}] = upcaster
+
+	return u
+}
+
+// Upcast applies the upcasters step by step until no upcaster is registered
+// for the type and schema version of the event. A nil chain returns the event
+// as is.
+func (u *Upcasters) Upcast(ctx context.Context, e EventReadModel) (EventReadModel, error) {
+	if u == nil {
+		return e, nil
+	}
+
+	for {
+		version := e.SchemaVersion()
+
+		u.mu.RLock()
+		upcaster, exists := u.steps[upcasterKey{eventType: e.Type, version: version}]
+		u.mu.RUnlock()
+
+		if !exists {
+			return e, nil
+		}
+
+		upcasted, err := upcaster(ctx, e)
+		if err != nil {
+			return e, fmt.Errorf("could not upcast event '%s' of type '%s' from schema version %d: %w", e.ID, e.Type, version, err)
+		}
+
+		metadata := make(map[string]interface{}, len(upcasted.Metadata)+1)
+		for key, value := range upcasted.Metadata {
+			metadata[key] = value
+		}
+
+		metadata[SchemaVersionMetadataKey] = version + 1
+		upcasted.Metadata = metadata
+
+		e = upcasted
+	}
+}
+
+// UpcastAll upcasts the events in order.
+func (u *Upcasters) UpcastAll(ctx context.Context, ee ...EventReadModel) ([]EventReadModel, error) {
+	if u == nil {
+		return ee, nil
+	}
+
+	results := make([]EventReadModel, 0, len(ee))
+	for _, e := range ee {
+		upcasted, err := u.Upcast(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, upcasted)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,115 @@
+package eventsource
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func organizationUpcasters() *Upcasters {
+	return NewUpcasters().
+		Register("organization_created", 1, func(_ context.Context, e EventReadModel) (EventReadModel, error) {
+			var v1 struct {
+				Title         string `json:"title"`
+				VATNumber     string `json:"vat_number"`
+				VATIsIntraCom bool   `json:"vat_is_intra_com"`
+			}
+			if err := json.Unmarshal(e.Data, &v1); err != nil {
+				return e, err
+			}
+
+			var v2 struct {
+				Name string `json:"name"`
+				VAT  struct {
+					Number     string `json:"number"`
+					IsIntraCom bool   `json:"is_intra_com"`
+				} `json:"vat"`
+			}
+			v2.Name = v1.Title
+			v2.VAT.Number = v1.VATNumber
+			v2.VAT.IsIntraCom = v1.VATIsIntraCom
+
+			data, err := json.Marshal(v2)
+			if err != nil {
+				return e, err
+			}
+
+			e.Data = data
+
+			return e, nil
+		}).
+		Register("organization_created", 2, func(_ context.Context, e EventReadModel) (EventReadModel, error) {
+			e.Type = "organization_registered"
+
+			return e, nil
+		})
+}
+
+func TestUpcasters_Upcast(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "upcasters", "*.input.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upcasters := organizationUpcasters()
+
+	for _, input := range inputs {
+		golden := strings.TrimSuffix(input, ".input.json") + ".golden.json"
+
+		t.Run(filepath.Base(golden), func(t *testing.T) {
+			var e EventReadModel
+			readFixture(t, input, &e)
+
+			upcasted, err := upcasters.Upcast(context.Background(), e)
+			if err != nil {
+				t.Fatalf("Upcast() error = %v", err)
+			}
+
+			b, err := json.Marshal(upcasted)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, want map[string]interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			readFixture(t, golden, &want)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Upcast() = %s, want content of %s", b, golden)
+			}
+		})
+	}
+}
+
+func TestUpcasters_NilChain(t *testing.T) {
+	e := EventReadModel{ID: "evt_1", Type: "organization_created", Data: json.RawMessage(`{"title":"The Fabric"}`)}
+
+	got, err := (*Upcasters)(nil).Upcast(context.Background(), e)
+	if err != nil {
+		t.Fatalf("Upcast() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("Upcast() = %+v, want %+v", got, e)
+	}
+}
+
+func readFixture(t *testing.T, path string, v any) {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatalf("could not decode %s: %v", path, err)
+	}
+}